@@ -1,22 +1,64 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	APIKey           string
-	BaseURL          string
-	Port             string
-	RateLimit        float64
-	MaxPromptLength  int
-	ReadTimeoutSecs  int
-	WriteTimeoutSecs int
-	IdleTimeoutSecs  int
+	APIKey               string
+	BaseURL              string
+	Port                 string
+	RateLimit            float64
+	MaxPromptLength      int
+	MaxTotalLength       int
+	ReadTimeoutSecs      int
+	WriteTimeoutSecs     int
+	IdleTimeoutSecs      int
+	DefaultModel         string
+	AllowedModels        []string
+	MaxTokensLimit       int
+	ModelDefaults        map[string]SamplingDefaults
+	SSERetryMillis       int
+	StreamBufferSecs     int
+	HeartbeatSecs        int
+	MaxContinuations     int
+	MaxToolIterations    int
+	MaxImageBytes        int
+	AllowedImageTypes    []string
+	MaxChoices           int
+	PromptTemplatesDir   string
+	ConversationStore    string
+	ConversationsDir     string
+	ContextWindows       map[string]int
+	DefaultContextWindow int
+	ContextStrategy      string
+	ContextKeepLast      int
+	Providers            []ProviderConfig
+	Fallbacks            map[string][]FallbackTarget
+}
+
+// Strategies for conversations that exceed the model context window
+const (
+	ContextDropOldest = "drop_oldest"
+	ContextKeepLast   = "keep_last"
+	ContextSummarize  = "summarize"
+)
+
+// SamplingDefaults holds per-model sampling parameters applied when a
+// request does not set them
+type SamplingDefaults struct {
+	Temperature      *float32 `json:"temperature,omitempty"`
+	TopP             *float32 `json:"top_p,omitempty"`
+	MaxTokens        *int     `json:"max_tokens,omitempty"`
+	PresencePenalty  *float32 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float32 `json:"frequency_penalty,omitempty"`
 }
 
 func LoadConfig() (*Config, error) {
@@ -26,25 +68,131 @@ func LoadConfig() (*Config, error) {
 
 	rateLimit, _ := strconv.ParseFloat(getEnvWithDefault("RATE_LIMIT", "10"), 64)
 	maxPromptLen, _ := strconv.Atoi(getEnvWithDefault("MAX_PROMPT_LENGTH", "4000"))
+	maxTotalLen, _ := strconv.Atoi(getEnvWithDefault("MAX_TOTAL_LENGTH", "32000"))
 	readTimeout, _ := strconv.Atoi(getEnvWithDefault("READ_TIMEOUT_SECS", "15"))
 	writeTimeout, _ := strconv.Atoi(getEnvWithDefault("WRITE_TIMEOUT_SECS", "15"))
 	idleTimeout, _ := strconv.Atoi(getEnvWithDefault("IDLE_TIMEOUT_SECS", "60"))
+	defaultModel := getEnvWithDefault("DEFAULT_MODEL", "anthropic/claude-3.5-sonnet")
+	maxTokensLimit, _ := strconv.Atoi(getEnvWithDefault("MAX_TOKENS_LIMIT", "4096"))
+	sseRetry, _ := strconv.Atoi(getEnvWithDefault("SSE_RETRY_MS", "3000"))
+	streamBuffer, _ := strconv.Atoi(getEnvWithDefault("STREAM_BUFFER_SECS", "120"))
+	heartbeat, _ := strconv.Atoi(getEnvWithDefault("HEARTBEAT_SECS", "15"))
+	maxContinuations, _ := strconv.Atoi(getEnvWithDefault("MAX_CONTINUATIONS", "2"))
+	maxToolIterations, _ := strconv.Atoi(getEnvWithDefault("MAX_TOOL_ITERATIONS", "5"))
+	maxImageBytes, _ := strconv.Atoi(getEnvWithDefault("MAX_IMAGE_BYTES", "5242880"))
+	maxChoices, _ := strconv.Atoi(getEnvWithDefault("MAX_CHOICES", "4"))
+	defaultContextWindow, _ := strconv.Atoi(getEnvWithDefault("DEFAULT_CONTEXT_WINDOW", "0"))
+	contextKeepLast, _ := strconv.Atoi(getEnvWithDefault("CONTEXT_KEEP_LAST", "10"))
+
+	modelDefaults := map[string]SamplingDefaults{}
+	if raw := os.Getenv("MODEL_DEFAULTS"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &modelDefaults); err != nil {
+			return nil, fmt.Errorf("invalid MODEL_DEFAULTS: %v", err)
+		}
+	}
+
+	contextWindows := map[string]int{}
+	if raw := os.Getenv("MODEL_CONTEXT_WINDOWS"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &contextWindows); err != nil {
+			return nil, fmt.Errorf("invalid MODEL_CONTEXT_WINDOWS: %v", err)
+		}
+	}
+
+	apiKey := os.Getenv("OPENROUTER_API_KEY")
+	baseURL := "https://openrouter.ai/api/v1"
+	providers, err := loadProviders(apiKey, baseURL)
+	if err != nil {
+		return nil, err
+	}
+	fallbacks, err := loadFallbacks(providers)
+	if err != nil {
+		return nil, err
+	}
+
+	contextStrategy := getEnvWithDefault("CONTEXT_STRATEGY", ContextDropOldest)
+	switch contextStrategy {
+	case ContextDropOldest, ContextKeepLast, ContextSummarize:
+	default:
+		return nil, fmt.Errorf("invalid CONTEXT_STRATEGY %q", contextStrategy)
+	}
 
 	return &Config{
-		APIKey:           os.Getenv("OPENROUTER_API_KEY"),
-		BaseURL:          "https://openrouter.ai/api/v1",
-		Port:             getEnvWithDefault("PORT", ":8080"),
-		RateLimit:        rateLimit,
-		MaxPromptLength:  maxPromptLen,
-		ReadTimeoutSecs:  readTimeout,
-		WriteTimeoutSecs: writeTimeout,
-		IdleTimeoutSecs:  idleTimeout,
+		APIKey:               apiKey,
+		BaseURL:              baseURL,
+		Port:                 getEnvWithDefault("PORT", ":8080"),
+		RateLimit:            rateLimit,
+		MaxPromptLength:      maxPromptLen,
+		MaxTotalLength:       maxTotalLen,
+		ReadTimeoutSecs:      readTimeout,
+		WriteTimeoutSecs:     writeTimeout,
+		IdleTimeoutSecs:      idleTimeout,
+		DefaultModel:         defaultModel,
+		AllowedModels:        splitList(getEnvWithDefault("ALLOWED_MODELS", defaultModel)),
+		MaxTokensLimit:       maxTokensLimit,
+		ModelDefaults:        modelDefaults,
+		SSERetryMillis:       sseRetry,
+		StreamBufferSecs:     streamBuffer,
+		HeartbeatSecs:        heartbeat,
+		MaxContinuations:     maxContinuations,
+		MaxToolIterations:    maxToolIterations,
+		MaxImageBytes:        maxImageBytes,
+		AllowedImageTypes:    splitList(getEnvWithDefault("ALLOWED_IMAGE_TYPES", "image/png,image/jpeg,image/gif,image/webp")),
+		MaxChoices:           maxChoices,
+		PromptTemplatesDir:   os.Getenv("PROMPT_TEMPLATES_DIR"),
+		ConversationStore:    getEnvWithDefault("CONVERSATION_STORE", "memory"),
+		ConversationsDir:     getEnvWithDefault("CONVERSATIONS_DIR", "./data/conversations"),
+		ContextWindows:       contextWindows,
+		DefaultContextWindow: defaultContextWindow,
+		ContextStrategy:      contextStrategy,
+		ContextKeepLast:      contextKeepLast,
+		Providers:            providers,
+		Fallbacks:            fallbacks,
 	}, nil
 }
 
+// IsModelAllowed reports whether clients may request the given model.
+// The default model and models listed by a provider are always allowed.
+func (c *Config) IsModelAllowed(model string) bool {
+	if model == c.DefaultModel {
+		return true
+	}
+	for _, allowed := range c.AllowedModels {
+		if model == allowed {
+			return true
+		}
+	}
+	for _, provider := range c.Providers {
+		for _, served := range provider.Models {
+			if model == served {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ContextWindow returns the context size in tokens for the model, or 0 when
+// the context window is not managed
+func (c *Config) ContextWindow(model string) int {
+	if window, ok := c.ContextWindows[model]; ok {
+		return window
+	}
+	return c.DefaultContextWindow
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func getEnvWithDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
-} 
\ No newline at end of file
+}
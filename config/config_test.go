@@ -10,13 +10,36 @@ import (
 func TestLoadConfig(t *testing.T) {
 	// Save current env vars
 	oldEnv := map[string]string{
-		"OPENROUTER_API_KEY":    os.Getenv("OPENROUTER_API_KEY"),
-		"PORT":                  os.Getenv("PORT"),
-		"RATE_LIMIT":           os.Getenv("RATE_LIMIT"),
-		"MAX_PROMPT_LENGTH":    os.Getenv("MAX_PROMPT_LENGTH"),
-		"READ_TIMEOUT_SECS":    os.Getenv("READ_TIMEOUT_SECS"),
-		"WRITE_TIMEOUT_SECS":   os.Getenv("WRITE_TIMEOUT_SECS"),
-		"IDLE_TIMEOUT_SECS":    os.Getenv("IDLE_TIMEOUT_SECS"),
+		"OPENROUTER_API_KEY":     os.Getenv("OPENROUTER_API_KEY"),
+		"PORT":                   os.Getenv("PORT"),
+		"RATE_LIMIT":             os.Getenv("RATE_LIMIT"),
+		"MAX_PROMPT_LENGTH":      os.Getenv("MAX_PROMPT_LENGTH"),
+		"MAX_TOTAL_LENGTH":       os.Getenv("MAX_TOTAL_LENGTH"),
+		"READ_TIMEOUT_SECS":      os.Getenv("READ_TIMEOUT_SECS"),
+		"WRITE_TIMEOUT_SECS":     os.Getenv("WRITE_TIMEOUT_SECS"),
+		"IDLE_TIMEOUT_SECS":      os.Getenv("IDLE_TIMEOUT_SECS"),
+		"DEFAULT_MODEL":          os.Getenv("DEFAULT_MODEL"),
+		"ALLOWED_MODELS":         os.Getenv("ALLOWED_MODELS"),
+		"MAX_TOKENS_LIMIT":       os.Getenv("MAX_TOKENS_LIMIT"),
+		"MODEL_DEFAULTS":         os.Getenv("MODEL_DEFAULTS"),
+		"SSE_RETRY_MS":           os.Getenv("SSE_RETRY_MS"),
+		"STREAM_BUFFER_SECS":     os.Getenv("STREAM_BUFFER_SECS"),
+		"HEARTBEAT_SECS":         os.Getenv("HEARTBEAT_SECS"),
+		"MAX_CONTINUATIONS":      os.Getenv("MAX_CONTINUATIONS"),
+		"MAX_TOOL_ITERATIONS":    os.Getenv("MAX_TOOL_ITERATIONS"),
+		"MAX_IMAGE_BYTES":        os.Getenv("MAX_IMAGE_BYTES"),
+		"ALLOWED_IMAGE_TYPES":    os.Getenv("ALLOWED_IMAGE_TYPES"),
+		"MAX_CHOICES":            os.Getenv("MAX_CHOICES"),
+		"PROMPT_TEMPLATES_DIR":   os.Getenv("PROMPT_TEMPLATES_DIR"),
+		"CONVERSATION_STORE":     os.Getenv("CONVERSATION_STORE"),
+		"CONVERSATIONS_DIR":      os.Getenv("CONVERSATIONS_DIR"),
+		"MODEL_CONTEXT_WINDOWS":  os.Getenv("MODEL_CONTEXT_WINDOWS"),
+		"DEFAULT_CONTEXT_WINDOW": os.Getenv("DEFAULT_CONTEXT_WINDOW"),
+		"CONTEXT_STRATEGY":       os.Getenv("CONTEXT_STRATEGY"),
+		"CONTEXT_KEEP_LAST":      os.Getenv("CONTEXT_KEEP_LAST"),
+		"PROVIDERS":              os.Getenv("PROVIDERS"),
+		"FALLBACKS":              os.Getenv("FALLBACKS"),
+		"LOCAL_API_KEY":          os.Getenv("LOCAL_API_KEY"),
 	}
 
 	// Restore env vars after test
@@ -41,26 +64,75 @@ func TestLoadConfig(t *testing.T) {
 				"OPENROUTER_API_KEY": "test-key",
 			},
 			expected: &Config{
-				APIKey:           "test-key",
-				BaseURL:          "https://openrouter.ai/api/v1",
-				Port:             ":8080",
-				RateLimit:        10,
-				MaxPromptLength:  4000,
-				ReadTimeoutSecs:  15,
-				WriteTimeoutSecs: 15,
-				IdleTimeoutSecs:  60,
+				APIKey:            "test-key",
+				BaseURL:           "https://openrouter.ai/api/v1",
+				Port:              ":8080",
+				RateLimit:         10,
+				MaxPromptLength:   4000,
+				MaxTotalLength:    32000,
+				ReadTimeoutSecs:   15,
+				WriteTimeoutSecs:  15,
+				IdleTimeoutSecs:   60,
+				DefaultModel:      "anthropic/claude-3.5-sonnet",
+				AllowedModels:     []string{"anthropic/claude-3.5-sonnet"},
+				MaxTokensLimit:    4096,
+				ModelDefaults:     map[string]SamplingDefaults{},
+				SSERetryMillis:    3000,
+				StreamBufferSecs:  120,
+				HeartbeatSecs:     15,
+				MaxContinuations:  2,
+				MaxToolIterations: 5,
+				MaxImageBytes:     5242880,
+				AllowedImageTypes: []string{"image/png", "image/jpeg", "image/gif", "image/webp"},
+				MaxChoices:        4,
+				ConversationStore: "memory",
+				ConversationsDir:  "./data/conversations",
+				ContextWindows:    map[string]int{},
+				ContextStrategy:   "drop_oldest",
+				ContextKeepLast:   10,
+				Providers: []ProviderConfig{
+					{Name: "openrouter", Type: "openai", BaseURL: "https://openrouter.ai/api/v1", APIKey: "test-key"},
+				},
+				Fallbacks: map[string][]FallbackTarget{},
 			},
 		},
 		{
 			name: "custom values",
 			envVars: map[string]string{
-				"OPENROUTER_API_KEY":    "custom-key",
-				"PORT":                  ":3000",
-				"RATE_LIMIT":           "20",
-				"MAX_PROMPT_LENGTH":    "5000",
-				"READ_TIMEOUT_SECS":    "30",
-				"WRITE_TIMEOUT_SECS":   "30",
-				"IDLE_TIMEOUT_SECS":    "120",
+				"OPENROUTER_API_KEY":     "custom-key",
+				"PORT":                   ":3000",
+				"RATE_LIMIT":             "20",
+				"MAX_PROMPT_LENGTH":      "5000",
+				"MAX_TOTAL_LENGTH":       "50000",
+				"READ_TIMEOUT_SECS":      "30",
+				"WRITE_TIMEOUT_SECS":     "30",
+				"IDLE_TIMEOUT_SECS":      "120",
+				"DEFAULT_MODEL":          "openai/gpt-4o",
+				"ALLOWED_MODELS":         "openai/gpt-4o, openai/gpt-4o-mini",
+				"MAX_TOKENS_LIMIT":       "8192",
+				"MODEL_DEFAULTS":         `{"openai/gpt-4o": {"temperature": 0.5, "max_tokens": 1024}}`,
+				"SSE_RETRY_MS":           "5000",
+				"STREAM_BUFFER_SECS":     "300",
+				"HEARTBEAT_SECS":         "5",
+				"MAX_CONTINUATIONS":      "4",
+				"MAX_TOOL_ITERATIONS":    "8",
+				"MAX_IMAGE_BYTES":        "1048576",
+				"ALLOWED_IMAGE_TYPES":    "image/png",
+				"MAX_CHOICES":            "2",
+				"PROMPT_TEMPLATES_DIR":   "./prompts",
+				"CONVERSATION_STORE":     "file",
+				"CONVERSATIONS_DIR":      "/var/lib/chat",
+				"MODEL_CONTEXT_WINDOWS":  `{"openai/gpt-4o": 128000}`,
+				"DEFAULT_CONTEXT_WINDOW": "8192",
+				"CONTEXT_STRATEGY":       "summarize",
+				"CONTEXT_KEEP_LAST":      "6",
+				"LOCAL_API_KEY":          "local-key",
+				"PROVIDERS": `[
+					{"name": "openai", "base_url": "https://api.openai.com/v1", "api_key": "sk-test", "models": ["gpt-4o"]},
+					{"name": "azure", "type": "azure", "base_url": "https://example.openai.azure.com", "api_version": "2024-06-01", "models": ["gpt-4o-mini"]},
+					{"name": "local", "base_url": "http://localhost:11434/v1", "api_key_env": "LOCAL_API_KEY"}
+				]`,
+				"FALLBACKS": `{"gpt-4o": [{"provider": "azure"}, {"provider": "local", "model": "llama3"}]}`,
 			},
 			expected: &Config{
 				APIKey:           "custom-key",
@@ -68,9 +140,39 @@ func TestLoadConfig(t *testing.T) {
 				Port:             ":3000",
 				RateLimit:        20,
 				MaxPromptLength:  5000,
+				MaxTotalLength:   50000,
 				ReadTimeoutSecs:  30,
 				WriteTimeoutSecs: 30,
 				IdleTimeoutSecs:  120,
+				DefaultModel:     "openai/gpt-4o",
+				AllowedModels:    []string{"openai/gpt-4o", "openai/gpt-4o-mini"},
+				MaxTokensLimit:   8192,
+				ModelDefaults: map[string]SamplingDefaults{
+					"openai/gpt-4o": {Temperature: float32Ptr(0.5), MaxTokens: intPtr(1024)},
+				},
+				SSERetryMillis:       5000,
+				StreamBufferSecs:     300,
+				HeartbeatSecs:        5,
+				MaxContinuations:     4,
+				MaxToolIterations:    8,
+				MaxImageBytes:        1048576,
+				AllowedImageTypes:    []string{"image/png"},
+				MaxChoices:           2,
+				PromptTemplatesDir:   "./prompts",
+				ConversationStore:    "file",
+				ConversationsDir:     "/var/lib/chat",
+				ContextWindows:       map[string]int{"openai/gpt-4o": 128000},
+				DefaultContextWindow: 8192,
+				ContextStrategy:      "summarize",
+				ContextKeepLast:      6,
+				Providers: []ProviderConfig{
+					{Name: "openai", Type: "openai", BaseURL: "https://api.openai.com/v1", APIKey: "sk-test", Models: []string{"gpt-4o"}},
+					{Name: "azure", Type: "azure", BaseURL: "https://example.openai.azure.com", APIVersion: "2024-06-01", Models: []string{"gpt-4o-mini"}},
+					{Name: "local", Type: "openai", BaseURL: "http://localhost:11434/v1", APIKey: "local-key", APIKeyEnv: "LOCAL_API_KEY"},
+				},
+				Fallbacks: map[string][]FallbackTarget{
+					"gpt-4o": {{Provider: "azure"}, {Provider: "local", Model: "llama3"}},
+				},
 			},
 		},
 	}
@@ -94,13 +196,176 @@ func TestLoadConfig(t *testing.T) {
 			assert.Equal(t, tt.expected.Port, cfg.Port)
 			assert.Equal(t, tt.expected.RateLimit, cfg.RateLimit)
 			assert.Equal(t, tt.expected.MaxPromptLength, cfg.MaxPromptLength)
+			assert.Equal(t, tt.expected.MaxTotalLength, cfg.MaxTotalLength)
 			assert.Equal(t, tt.expected.ReadTimeoutSecs, cfg.ReadTimeoutSecs)
 			assert.Equal(t, tt.expected.WriteTimeoutSecs, cfg.WriteTimeoutSecs)
 			assert.Equal(t, tt.expected.IdleTimeoutSecs, cfg.IdleTimeoutSecs)
+			assert.Equal(t, tt.expected.DefaultModel, cfg.DefaultModel)
+			assert.Equal(t, tt.expected.AllowedModels, cfg.AllowedModels)
+			assert.Equal(t, tt.expected.MaxTokensLimit, cfg.MaxTokensLimit)
+			assert.Equal(t, tt.expected.ModelDefaults, cfg.ModelDefaults)
+			assert.Equal(t, tt.expected.SSERetryMillis, cfg.SSERetryMillis)
+			assert.Equal(t, tt.expected.StreamBufferSecs, cfg.StreamBufferSecs)
+			assert.Equal(t, tt.expected.HeartbeatSecs, cfg.HeartbeatSecs)
+			assert.Equal(t, tt.expected.MaxContinuations, cfg.MaxContinuations)
+			assert.Equal(t, tt.expected.MaxToolIterations, cfg.MaxToolIterations)
+			assert.Equal(t, tt.expected.MaxImageBytes, cfg.MaxImageBytes)
+			assert.Equal(t, tt.expected.AllowedImageTypes, cfg.AllowedImageTypes)
+			assert.Equal(t, tt.expected.MaxChoices, cfg.MaxChoices)
+			assert.Equal(t, tt.expected.PromptTemplatesDir, cfg.PromptTemplatesDir)
+			assert.Equal(t, tt.expected.ConversationStore, cfg.ConversationStore)
+			assert.Equal(t, tt.expected.ConversationsDir, cfg.ConversationsDir)
+			assert.Equal(t, tt.expected.ContextWindows, cfg.ContextWindows)
+			assert.Equal(t, tt.expected.DefaultContextWindow, cfg.DefaultContextWindow)
+			assert.Equal(t, tt.expected.ContextStrategy, cfg.ContextStrategy)
+			assert.Equal(t, tt.expected.ContextKeepLast, cfg.ContextKeepLast)
+			assert.Equal(t, tt.expected.Providers, cfg.Providers)
+			assert.Equal(t, tt.expected.Fallbacks, cfg.Fallbacks)
+		})
+	}
+}
+
+func TestLoadConfig_InvalidModelDefaults(t *testing.T) {
+	os.Setenv("MODEL_DEFAULTS", "{not json")
+	defer os.Unsetenv("MODEL_DEFAULTS")
+
+	_, err := LoadConfig()
+	assert.Error(t, err)
+}
+
+func TestLoadConfig_InvalidContextStrategy(t *testing.T) {
+	os.Setenv("CONTEXT_STRATEGY", "truncate")
+	defer os.Unsetenv("CONTEXT_STRATEGY")
+
+	_, err := LoadConfig()
+	assert.EqualError(t, err, `invalid CONTEXT_STRATEGY "truncate"`)
+}
+
+func TestLoadConfig_InvalidProviders(t *testing.T) {
+	defer os.Unsetenv("PROVIDERS")
+
+	tests := []struct {
+		name      string
+		providers string
+		wantErr   string
+	}{
+		{
+			name:      "not json",
+			providers: "[",
+			wantErr:   "invalid PROVIDERS: unexpected end of JSON input",
+		},
+		{
+			name:      "empty",
+			providers: "[]",
+			wantErr:   "invalid PROVIDERS: at least one provider is required",
+		},
+		{
+			name:      "missing name",
+			providers: `[{"base_url": "http://a"}]`,
+			wantErr:   "invalid PROVIDERS: provider 0 has no name",
+		},
+		{
+			name:      "duplicate name",
+			providers: `[{"name": "a", "base_url": "http://a", "models": ["x"]}, {"name": "a", "base_url": "http://b"}]`,
+			wantErr:   `invalid PROVIDERS: duplicate provider "a"`,
+		},
+		{
+			name:      "unknown type",
+			providers: `[{"name": "a", "type": "bedrock", "base_url": "http://a"}]`,
+			wantErr:   `invalid PROVIDERS: provider "a" has unknown type "bedrock"`,
+		},
+		{
+			name:      "missing base url",
+			providers: `[{"name": "a"}]`,
+			wantErr:   `invalid PROVIDERS: provider "a" has no base_url`,
+		},
+		{
+			name:      "azure without version",
+			providers: `[{"name": "a", "type": "azure", "base_url": "http://a"}]`,
+			wantErr:   `invalid PROVIDERS: provider "a" requires an api_version`,
+		},
+		{
+			name:      "model listed twice",
+			providers: `[{"name": "a", "base_url": "http://a", "models": ["x"]}, {"name": "b", "base_url": "http://b", "models": ["x"]}]`,
+			wantErr:   `invalid PROVIDERS: model "x" is listed by providers "a" and "b"`,
+		},
+		{
+			name:      "two fallbacks",
+			providers: `[{"name": "a", "base_url": "http://a"}, {"name": "b", "base_url": "http://b"}]`,
+			wantErr:   `invalid PROVIDERS: providers "a" and "b" both list no models`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("PROVIDERS", tt.providers)
+			_, err := LoadConfig()
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestLoadConfig_InvalidFallbacks(t *testing.T) {
+	defer os.Unsetenv("FALLBACKS")
+
+	tests := []struct {
+		name      string
+		fallbacks string
+		wantErr   string
+	}{
+		{
+			name:      "not json",
+			fallbacks: "{",
+			wantErr:   "invalid FALLBACKS: unexpected end of JSON input",
+		},
+		{
+			name:      "empty target",
+			fallbacks: `{"gpt-4o": [{}]}`,
+			wantErr:   `invalid FALLBACKS: target 0 of "gpt-4o" needs a provider or model`,
+		},
+		{
+			name:      "unknown provider",
+			fallbacks: `{"gpt-4o": [{"model": "gpt-4o-mini"}, {"provider": "azure"}]}`,
+			wantErr:   `invalid FALLBACKS: target 1 of "gpt-4o" has unknown provider "azure"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("FALLBACKS", tt.fallbacks)
+			_, err := LoadConfig()
+			assert.EqualError(t, err, tt.wantErr)
 		})
 	}
 }
 
+func float32Ptr(v float32) *float32 { return &v }
+
+func intPtr(v int) *int { return &v }
+
+func TestIsModelAllowed(t *testing.T) {
+	cfg := &Config{
+		DefaultModel:  "default/model",
+		AllowedModels: []string{"allowed/model"},
+		Providers:     []ProviderConfig{{Name: "local", Models: []string{"local/model"}}},
+	}
+
+	assert.True(t, cfg.IsModelAllowed("default/model"))
+	assert.True(t, cfg.IsModelAllowed("allowed/model"))
+	assert.True(t, cfg.IsModelAllowed("local/model"))
+	assert.False(t, cfg.IsModelAllowed("other/model"))
+}
+
+func TestContextWindow(t *testing.T) {
+	cfg := &Config{
+		ContextWindows:       map[string]int{"openai/gpt-4o": 128000},
+		DefaultContextWindow: 8192,
+	}
+
+	assert.Equal(t, 128000, cfg.ContextWindow("openai/gpt-4o"))
+	assert.Equal(t, 8192, cfg.ContextWindow("other/model"))
+}
+
 func TestGetEnvWithDefault(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -136,4 +401,4 @@ func TestGetEnvWithDefault(t *testing.T) {
 			assert.Equal(t, tt.expected, result)
 		})
 	}
-} 
\ No newline at end of file
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Provider types: any OpenAI-compatible API (OpenAI, OpenRouter, Ollama,
+// vLLM, ...) or Azure OpenAI
+const (
+	ProviderOpenAI = "openai"
+	ProviderAzure  = "azure"
+)
+
+// ProviderConfig describes one upstream backend
+type ProviderConfig struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	BaseURL string `json:"base_url"`
+	APIKey  string `json:"api_key,omitempty"`
+	// APIKeyEnv names the environment variable holding the key, so that
+	// secrets can stay out of PROVIDERS
+	APIKeyEnv string `json:"api_key_env,omitempty"`
+	// APIVersion is required by Azure OpenAI
+	APIVersion string `json:"api_version,omitempty"`
+	// Models are routed to this provider. A provider without models serves
+	// every model no other provider lists.
+	Models []string `json:"models,omitempty"`
+}
+
+// FallbackTarget is one step of a model's failover chain. An empty
+// Provider routes Model as usual and an empty Model keeps the requested one.
+type FallbackTarget struct {
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+}
+
+// loadProviders parses PROVIDERS, defaulting to OpenRouter alone
+func loadProviders(apiKey, baseURL string) ([]ProviderConfig, error) {
+	raw := os.Getenv("PROVIDERS")
+	if raw == "" {
+		return []ProviderConfig{{Name: "openrouter", Type: ProviderOpenAI, BaseURL: baseURL, APIKey: apiKey}}, nil
+	}
+
+	var providers []ProviderConfig
+	if err := json.Unmarshal([]byte(raw), &providers); err != nil {
+		return nil, fmt.Errorf("invalid PROVIDERS: %v", err)
+	}
+	for i := range providers {
+		if providers[i].Type == "" {
+			providers[i].Type = ProviderOpenAI
+		}
+		if providers[i].APIKeyEnv != "" {
+			providers[i].APIKey = os.Getenv(providers[i].APIKeyEnv)
+		}
+	}
+	if err := validateProviders(providers); err != nil {
+		return nil, fmt.Errorf("invalid PROVIDERS: %v", err)
+	}
+	return providers, nil
+}
+
+// loadFallbacks parses FALLBACKS, a JSON object mapping a model to the
+// targets tried in order when its provider fails
+func loadFallbacks(providers []ProviderConfig) (map[string][]FallbackTarget, error) {
+	fallbacks := map[string][]FallbackTarget{}
+	raw := os.Getenv("FALLBACKS")
+	if raw == "" {
+		return fallbacks, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &fallbacks); err != nil {
+		return nil, fmt.Errorf("invalid FALLBACKS: %v", err)
+	}
+
+	names := map[string]bool{}
+	for _, provider := range providers {
+		names[provider.Name] = true
+	}
+	for model, targets := range fallbacks {
+		for i, target := range targets {
+			if target.Provider == "" && target.Model == "" {
+				return nil, fmt.Errorf("invalid FALLBACKS: target %d of %q needs a provider or model", i, model)
+			}
+			if target.Provider != "" && !names[target.Provider] {
+				return nil, fmt.Errorf("invalid FALLBACKS: target %d of %q has unknown provider %q", i, model, target.Provider)
+			}
+		}
+	}
+	return fallbacks, nil
+}
+
+func validateProviders(providers []ProviderConfig) error {
+	if len(providers) == 0 {
+		return fmt.Errorf("at least one provider is required")
+	}
+	names := map[string]bool{}
+	owners := map[string]string{}
+	fallback := ""
+	for i, provider := range providers {
+		switch {
+		case provider.Name == "":
+			return fmt.Errorf("provider %d has no name", i)
+		case names[provider.Name]:
+			return fmt.Errorf("duplicate provider %q", provider.Name)
+		case provider.Type != ProviderOpenAI && provider.Type != ProviderAzure:
+			return fmt.Errorf("provider %q has unknown type %q", provider.Name, provider.Type)
+		case provider.BaseURL == "":
+			return fmt.Errorf("provider %q has no base_url", provider.Name)
+		case provider.Type == ProviderAzure && provider.APIVersion == "":
+			return fmt.Errorf("provider %q requires an api_version", provider.Name)
+		}
+		names[provider.Name] = true
+
+		if len(provider.Models) == 0 {
+			if fallback != "" {
+				return fmt.Errorf("providers %q and %q both list no models", fallback, provider.Name)
+			}
+			fallback = provider.Name
+		}
+		for _, model := range provider.Models {
+			if owner, ok := owners[model]; ok {
+				return fmt.Errorf("model %q is listed by providers %q and %q", model, owner, provider.Name)
+			}
+			owners[model] = provider.Name
+		}
+	}
+	return nil
+}
@@ -6,6 +6,26 @@ import (
 	"time"
 )
 
+// Error types shared by HTTP error bodies and SSE error events
+const (
+	TypeBadRequest            = "bad_request"
+	TypeUnauthorized          = "unauthorized"
+	TypeNotFound              = "not_found"
+	TypeInternalServer        = "internal_server_error"
+	TypeTooManyRequests       = "too_many_requests"
+	TypePayloadTooLarge       = "payload_too_large"
+	TypeBadGateway            = "bad_gateway"
+	TypeServiceUnavailable    = "service_unavailable"
+	TypeUpstreamError         = "upstream_error"
+	TypeUpstreamTimeout       = "upstream_timeout"
+	TypeRateLimited           = "rate_limited"
+	TypeContentFilter         = "content_filter"
+	TypeContextLengthExceeded = "context_length_exceeded"
+	TypeClientDisconnected    = "client_disconnected"
+	TypeToolLoopExceeded      = "tool_loop_exceeded"
+	TypeSchemaValidation      = "schema_validation_failed"
+)
+
 type APIError struct {
 	Message    string `json:"message"`
 	Code       int    `json:"code"`
@@ -41,18 +61,34 @@ func (e *APIError) RespondWithError(w http.ResponseWriter) {
 // Common error types
 var (
 	ErrBadRequest = func(msg string) *APIError {
-		return NewAPIError(msg, http.StatusBadRequest).WithType("bad_request")
+		return NewAPIError(msg, http.StatusBadRequest).WithType(TypeBadRequest)
 	}
 	
 	ErrUnauthorized = func(msg string) *APIError {
-		return NewAPIError(msg, http.StatusUnauthorized).WithType("unauthorized")
+		return NewAPIError(msg, http.StatusUnauthorized).WithType(TypeUnauthorized)
 	}
 	
+	ErrNotFound = func(msg string) *APIError {
+		return NewAPIError(msg, http.StatusNotFound).WithType(TypeNotFound)
+	}
+
 	ErrInternalServer = func(msg string) *APIError {
-		return NewAPIError(msg, http.StatusInternalServerError).WithType("internal_server_error")
+		return NewAPIError(msg, http.StatusInternalServerError).WithType(TypeInternalServer)
 	}
 
 	ErrTooManyRequests = func(msg string) *APIError {
-		return NewAPIError(msg, http.StatusTooManyRequests).WithType("too_many_requests")
+		return NewAPIError(msg, http.StatusTooManyRequests).WithType(TypeTooManyRequests)
+	}
+
+	ErrPayloadTooLarge = func(msg string) *APIError {
+		return NewAPIError(msg, http.StatusRequestEntityTooLarge).WithType(TypePayloadTooLarge)
+	}
+
+	ErrBadGateway = func(msg string) *APIError {
+		return NewAPIError(msg, http.StatusBadGateway).WithType(TypeBadGateway)
+	}
+
+	ErrServiceUnavailable = func(msg string) *APIError {
+		return NewAPIError(msg, http.StatusServiceUnavailable).WithType(TypeServiceUnavailable)
 	}
 ) 
\ No newline at end of file
@@ -90,6 +90,13 @@ func TestCommonErrors(t *testing.T) {
 			expectedType:   "unauthorized",
 			expectedStatus: http.StatusUnauthorized,
 		},
+		{
+			name:           "not found error",
+			errorFunc:      ErrNotFound,
+			expectedCode:   http.StatusNotFound,
+			expectedType:   "not_found",
+			expectedStatus: http.StatusNotFound,
+		},
 		{
 			name:           "internal server error",
 			errorFunc:      ErrInternalServer,
@@ -104,6 +111,27 @@ func TestCommonErrors(t *testing.T) {
 			expectedType:   "too_many_requests",
 			expectedStatus: http.StatusTooManyRequests,
 		},
+		{
+			name:           "payload too large error",
+			errorFunc:      ErrPayloadTooLarge,
+			expectedCode:   http.StatusRequestEntityTooLarge,
+			expectedType:   "payload_too_large",
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "bad gateway error",
+			errorFunc:      ErrBadGateway,
+			expectedCode:   http.StatusBadGateway,
+			expectedType:   "bad_gateway",
+			expectedStatus: http.StatusBadGateway,
+		},
+		{
+			name:           "service unavailable error",
+			errorFunc:      ErrServiceUnavailable,
+			expectedCode:   http.StatusServiceUnavailable,
+			expectedType:   "service_unavailable",
+			expectedStatus: http.StatusServiceUnavailable,
+		},
 	}
 
 	for _, tt := range tests {
@@ -3,17 +3,21 @@ package handlers
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"golang-ai-stream/config"
+	apierrors "golang-ai-stream/errors"
 	"golang-ai-stream/logger"
 	"golang-ai-stream/middleware"
 	"golang-ai-stream/models"
+	"golang-ai-stream/store"
 
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -29,164 +33,398 @@ type OpenAIClient interface {
 }
 
 type ChatHandler struct {
-	client OpenAIClient
-	config *config.Config
+	client    OpenAIClient
+	config    *config.Config
+	streams   *StreamStore
+	tools     *ToolRegistry
+	templates *PromptTemplates
+	// conversations is nil when conversations are not stored
+	conversations store.ConversationStore
 }
 
-func NewChatHandler(client OpenAIClient, cfg *config.Config) *ChatHandler {
+// NewChatHandler creates a handler; tools may be nil when the server
+// executes no tools itself
+func NewChatHandler(client OpenAIClient, cfg *config.Config, tools *ToolRegistry) *ChatHandler {
 	return &ChatHandler{
-		client: client,
-		config: cfg,
+		client:  client,
+		config:  cfg,
+		streams: NewStreamStore(time.Duration(cfg.StreamBufferSecs) * time.Second),
+		tools:   tools,
 	}
 }
 
+var validRoles = map[string]bool{
+	openai.ChatMessageRoleSystem:    true,
+	openai.ChatMessageRoleUser:      true,
+	openai.ChatMessageRoleAssistant: true,
+	openai.ChatMessageRoleTool:      true,
+}
+
 func (h *ChatHandler) validateRequest(reqBody *models.ChatRequest) error {
-	if strings.TrimSpace(reqBody.Prompt) == "" {
+	if reqBody.Model != "" && !h.config.IsModelAllowed(reqBody.Model) {
+		return fmt.Errorf("model %q is not allowed", reqBody.Model)
+	}
+	if len(reqBody.Messages) == 0 && strings.TrimSpace(reqBody.Prompt) == "" && !reqBody.Regenerate {
 		return fmt.Errorf("prompt cannot be empty")
 	}
 	if len(reqBody.Prompt) > h.config.MaxPromptLength {
-		return fmt.Errorf("prompt exceeds maximum length of %d characters", h.config.MaxPromptLength)
+		return tooLargeError{fmt.Errorf("prompt exceeds maximum length of %d characters", h.config.MaxPromptLength)}
+	}
+
+	total := len(reqBody.Prompt)
+	for i, msg := range reqBody.Messages {
+		if !validRoles[msg.Role] {
+			return fmt.Errorf("message %d has invalid role %q", i, msg.Role)
+		}
+		if msg.Role == openai.ChatMessageRoleTool && msg.ToolCallID == "" {
+			return fmt.Errorf("message %d tool result requires tool_call_id", i)
+		}
+		// Assistant turns that only call tools and tool results may be empty
+		if strings.TrimSpace(msg.Content) == "" && len(msg.Parts) == 0 && len(msg.ToolCalls) == 0 && msg.Role != openai.ChatMessageRoleTool {
+			return fmt.Errorf("message %d content cannot be empty", i)
+		}
+		size := len(msg.Content)
+		if len(msg.Parts) > 0 {
+			partsSize, err := h.validateParts(i, msg.Parts)
+			if err != nil {
+				return err
+			}
+			size += partsSize
+		}
+		if size > h.config.MaxPromptLength {
+			return tooLargeError{fmt.Errorf("message %d exceeds maximum length of %d characters", i, h.config.MaxPromptLength)}
+		}
+		total += size
+	}
+	if h.config.MaxTotalLength > 0 && total > h.config.MaxTotalLength {
+		return tooLargeError{fmt.Errorf("conversation exceeds maximum total length of %d characters", h.config.MaxTotalLength)}
+	}
+	if err := validateTools(reqBody); err != nil {
+		return err
+	}
+	if err := validateResponseFormat(reqBody); err != nil {
+		return err
+	}
+	if err := validateChoices(reqBody, h.config.MaxChoices); err != nil {
+		return err
+	}
+	if err := h.validateConversation(reqBody); err != nil {
+		return err
 	}
-	return nil
+	for i, tool := range reqBody.Tools {
+		if h.tools.Has(tool.Function.Name) {
+			return fmt.Errorf("tool %d name %q is reserved by a server tool", i, tool.Function.Name)
+		}
+	}
+	return validateSampling(reqBody, h.config.MaxTokensLimit)
 }
 
-func (h *ChatHandler) HandleChat(w http.ResponseWriter, r *http.Request) {
-	requestID := r.Context().Value(middleware.RequestIDKey).(string)
-	
-	// Set headers before any potential error responses
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Transfer-Encoding", "chunked")
+// resolveModel returns the requested model or the configured default
+func (h *ChatHandler) resolveModel(reqBody *models.ChatRequest) string {
+	if reqBody.Model != "" {
+		return reqBody.Model
+	}
+	return h.config.DefaultModel
+}
 
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		logger.LogError(requestID, fmt.Errorf("streaming not supported"), "Streaming unsupported")
-		chunk := models.ChatResponse{
-			Content:   "Streaming unsupported by client",
-			RequestID: requestID,
-			Type:     "error",
+// buildMessages converts the request history into upstream messages, appending
+// the prompt as the final user turn when present
+func buildMessages(reqBody *models.ChatRequest) []openai.ChatCompletionMessage {
+	messages := make([]openai.ChatCompletionMessage, 0, len(reqBody.Messages)+1)
+	for _, msg := range reqBody.Messages {
+		message := openai.ChatCompletionMessage{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCalls:  toOpenAIToolCalls(msg.ToolCalls),
+			ToolCallID: msg.ToolCallID,
 		}
-		writeSSEMessage(w, flusher, chunk)
-		return
+		// Content and MultiContent are mutually exclusive upstream
+		if len(msg.Parts) > 0 {
+			message.Content = ""
+			message.MultiContent = toOpenAIParts(msg.Parts)
+		}
+		messages = append(messages, message)
+	}
+	if strings.TrimSpace(reqBody.Prompt) != "" {
+		messages = append(messages, openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: reqBody.Prompt})
 	}
+	return messages
+}
+
+// buildChatRequest assembles the upstream request for a validated chat request
+func (h *ChatHandler) buildChatRequest(reqBody *models.ChatRequest) openai.ChatCompletionRequest {
+	model := h.resolveModel(reqBody)
+	chatReq := openai.ChatCompletionRequest{
+		Model:         model,
+		Messages:      buildMessages(reqBody),
+		Stream:        true,
+		StreamOptions: &openai.StreamOptions{IncludeUsage: true},
+	}
+	applySampling(&chatReq, reqBody, h.config.ModelDefaults[model])
+	applyTools(&chatReq, reqBody)
+	// Server tools run in a single conversation, so alternatives don't get them
+	if n := choiceCount(reqBody); n > 1 {
+		chatReq.N = n
+	} else {
+		appendTools(&chatReq, h.tools.Definitions())
+	}
+	applyResponseFormat(&chatReq, reqBody)
+	return chatReq
+}
+
+func (h *ChatHandler) HandleChat(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(middleware.RequestIDKey).(string)
 
+	// Failures before the first streamed byte are reported with real status codes
 	var reqBody models.ChatRequest
 	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
 		logger.LogError(requestID, err, "Invalid request payload")
-		chunk := models.ChatResponse{
-			Content:   "Invalid request payload",
-			RequestID: requestID,
-			Type:     "error",
-		}
-		writeSSEMessage(w, flusher, chunk)
+		apierrors.ErrBadRequest("Invalid request payload").WithRequestID(requestID).RespondWithError(w)
 		return
 	}
+	h.serveChat(w, r, &reqBody)
+}
+
+// serveChat validates a decoded chat request and streams or returns the reply
+func (h *ChatHandler) serveChat(w http.ResponseWriter, r *http.Request, reqBody *models.ChatRequest) {
+	requestID := r.Context().Value(middleware.RequestIDKey).(string)
+	tracker := newUsageTracker()
 
-	if err := h.validateRequest(&reqBody); err != nil {
+	if err := h.validateRequest(reqBody); err != nil {
 		logger.LogError(requestID, err, "Request validation failed")
-		chunk := models.ChatResponse{
-			Content:   err.Error(),
-			RequestID: requestID,
-			Type:     "error",
-		}
-		writeSSEMessage(w, flusher, chunk)
+		validationError(err).WithRequestID(requestID).RespondWithError(w)
 		return
 	}
 
+	// Stored history and the server-side system prompt do not count towards
+	// the client limits
+	branch, err := h.loadBranch(r.Context(), reqBody)
+	if err != nil {
+		logger.LogError(requestID, err, "Failed to load conversation")
+		branchError(err).WithRequestID(requestID).RespondWithError(w)
+		return
+	}
+	if err := h.applyTemplate(reqBody); err != nil {
+		logger.LogError(requestID, err, "Prompt template failed")
+		apierrors.ErrBadRequest(err.Error()).WithRequestID(requestID).RespondWithError(w)
+		return
+	}
+
+	jsonMode := wantsJSON(r, reqBody)
+	flusher, ok := w.(http.Flusher)
+	if !ok && !jsonMode {
+		logger.LogError(requestID, fmt.Errorf("streaming not supported"), "Streaming unsupported")
+		apierrors.ErrInternalServer("Streaming unsupported").WithRequestID(requestID).RespondWithError(w)
+		return
+	}
+
+	chatReq := h.buildChatRequest(reqBody)
+	contextTrim, err := h.fitContext(r.Context(), requestID, &chatReq)
+	if err != nil {
+		logger.LogError(requestID, err, "Conversation exceeds context window")
+		validationError(err).WithRequestID(requestID).RespondWithError(w)
+		return
+	}
 	logger.LogRequest(logger.INFO, requestID, r.Method, r.URL.Path, http.StatusOK, 0, 
-		fmt.Sprintf("Processing chat request with prompt length: %d", len(reqBody.Prompt)))
+		fmt.Sprintf("Processing chat request for model %s with %d messages", chatReq.Model, len(chatReq.Messages)))
 
-	chatReq := openai.ChatCompletionRequest{
-		Model:    "anthropic/claude-3.5-sonnet",
-		Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: reqBody.Prompt}},
-		Stream:   true,
+	// SSE generations are detached from the request so that a dropped client
+	// can resume from the stream buffer instead of losing the completion.
+	// They are cancelled once nobody has resumed them for the buffer window.
+	ctx, cancel := r.Context(), context.CancelFunc(func() {})
+	resumable := !jsonMode && h.config.StreamBufferSecs > 0
+	if resumable {
+		ctx, cancel = context.WithCancel(context.WithoutCancel(r.Context()))
 	}
 
-	stream, err := h.client.CreateChatCompletionStream(r.Context(), chatReq)
+	stream, err := h.client.CreateChatCompletionStream(ctx, chatReq)
 	if err != nil {
-		logger.LogError(fmt.Sprintf("[%s] Error creating chat completion stream", requestID), err, "error")
-		chunk := models.ChatResponse{
-			Content:   "Failed to create chat completion stream",
-			RequestID: requestID,
-			Type:     "error",
-		}
-		writeSSEMessage(w, flusher, chunk)
+		cancel()
+		logger.LogError(requestID, err, "Error creating chat completion stream")
+		upstreamError(err, "Failed to create chat completion stream").WithRequestID(requestID).RespondWithError(w)
 		return
 	}
-	defer stream.Close()
+	// Later calls for continuations, tools or repairs use the model that
+	// actually served the request
+	provider, model := servedBy(stream, chatReq.Model)
+	chatReq.Model = model
 
-	errCh := make(chan error, 1)
-	go func() {
-		for {
-			response, err := stream.Recv()
-			if err != nil {
-				errCh <- err
-				return
-			}
+	job := &streamJob{
+		requestID:    requestID,
+		chatReq:      chatReq,
+		tracker:      tracker,
+		autoContinue: reqBody.AutoContinue,
+		format:       reqBody.ResponseFormat,
+		reasoning:    reqBody.IncludeReasoning,
+		branch:       branch,
+		contextTrim:  contextTrim,
+		provider:     provider,
+	}
 
-			content := response.Choices[0].Delta.Content
-			if content == "" {
-				continue
-			}
+	if jsonMode {
+		defer cancel()
+		h.respondJSON(w, r, stream, job)
+		return
+	}
 
-			chunk := models.ChatResponse{
-				Content:   content,
-				RequestID: requestID,
-				Type:     "content",
-			}
-			if err := writeSSEMessage(w, flusher, chunk); err != nil {
-				errCh <- err
-				return
-			}
+	// Streams are resumed by a server-generated id: request ids come from
+	// the client and may be reused
+	buf, streamID := newStreamBuffer(), ""
+	if resumable {
+		streamID = uuid.NewString()
+		if buf, ok = h.streams.create(streamID); !ok {
+			cancel()
+			stream.Close()
+			logger.LogError(requestID, fmt.Errorf("stream id %s already in use", streamID), "Failed to buffer stream")
+			apierrors.ErrInternalServer("Failed to buffer stream").WithRequestID(requestID).RespondWithError(w)
+			return
 		}
-	}()
+		buf.abandonAfter(h.streams.retention, streamErrorChunk(requestID, context.Canceled), func() {
+			logger.LogInfo(fmt.Sprintf("[%s] Stream %s was not resumed, cancelling generation", requestID, streamID))
+			cancel()
+		})
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Transfer-Encoding", "chunked")
 
-	select {
-	case <-r.Context().Done():
-		logger.LogInfo(fmt.Sprintf("[%s] Client disconnected", requestID))
-		chunk := models.ChatResponse{
-			Content:   "Client disconnected",
-			RequestID: requestID,
-			Type:     "error",
+	// Sent once the upstream accepted the request, so that failures up to
+	// this point still get real status codes
+	connected := h.connectedChunk(requestID, chatReq.Model, resumable)
+	connected.Meta.StreamID = streamID
+	connected.Meta.Provider = provider
+	connected.Meta.Branch = branch.meta()
+	buf.Append(connected)
+	if contextTrim != nil {
+		buf.Append(models.ChatResponse{RequestID: requestID, Type: "context", Context: contextTrim})
+	}
+	go func() {
+		defer cancel()
+		h.pumpStream(ctx, stream, buf, job)
+		if resumable {
+			h.streams.finish(streamID, buf)
+		} else {
+			buf.Close()
 		}
-		writeSSEMessage(w, flusher, chunk)
+	}()
+
+	sse := h.newSSEWriter(w, flusher)
+	tailStream(r.Context(), sse, buf, 0, requestID, h.heartbeatInterval())
+}
+
+func (h *ChatHandler) heartbeatInterval() time.Duration {
+	return time.Duration(h.config.HeartbeatSecs) * time.Second
+}
+
+// newSSEWriter creates a writer that pushes the server write deadline forward
+// on every event and ping, so WRITE_TIMEOUT_SECS bounds a single write
+// instead of the whole stream
+func (h *ChatHandler) newSSEWriter(w http.ResponseWriter, flusher http.Flusher) *sseWriter {
+	sse := newSSEWriter(w, flusher, h.config.SSERetryMillis)
+	sse.writeTimeout = time.Duration(h.config.WriteTimeoutSecs) * time.Second
+	return sse
+}
+
+func (h *ChatHandler) connectedChunk(requestID, model string, resumable bool) models.ChatResponse {
+	limits := &models.StreamLimits{
+		MaxPromptLength: h.config.MaxPromptLength,
+		MaxTotalLength:  h.config.MaxTotalLength,
+		MaxTokens:       h.config.MaxTokensLimit,
+	}
+	if resumable {
+		limits.ResumeWindowSecs = h.config.StreamBufferSecs
+	}
+	return models.ChatResponse{
+		Content:   "",
+		RequestID: requestID,
+		Type:      "connected",
+		Meta:      &models.StreamMeta{Model: model, Limits: limits},
+	}
+}
+
+// HandleStreamResume replays the events of a buffered stream after the
+// Last-Event-ID sent by the client and keeps tailing it until it finishes
+func (h *ChatHandler) HandleStreamResume(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(middleware.RequestIDKey).(string)
+	streamID := mux.Vars(r)["stream_id"]
+
+	buf, ok := h.streams.get(streamID)
+	if !ok {
+		apierrors.ErrNotFound("Stream not found or expired").WithRequestID(requestID).RespondWithError(w)
 		return
-	case err := <-errCh:
-		if errors.Is(err, context.Canceled) {
-			return // Already handled by context.Done() case
-		}
-		if err == io.EOF {
-			chunk := models.ChatResponse{
-				Content:   "",
-				RequestID: requestID,
-				Type:     "done",
-			}
-			writeSSEMessage(w, flusher, chunk)
+	}
+
+	lastID := 0
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		id, err := strconv.Atoi(header)
+		if err != nil || id < 0 {
+			apierrors.ErrBadRequest("Invalid Last-Event-ID header").WithRequestID(requestID).RespondWithError(w)
 			return
 		}
-		logger.LogError(fmt.Sprintf("[%s] Failed to receive chat completion", requestID), err, "error")
-		chunk := models.ChatResponse{
-			Content:   "Failed to create chat completion stream",
-			RequestID: requestID,
-			Type:     "error",
-		}
-		writeSSEMessage(w, flusher, chunk)
+		lastID = id
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		apierrors.ErrInternalServer("Streaming unsupported").WithRequestID(requestID).RespondWithError(w)
 		return
 	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("Transfer-Encoding", "chunked")
+
+	logger.LogInfo(fmt.Sprintf("[%s] Resuming stream %s after event %d", requestID, streamID, lastID))
+	sse := h.newSSEWriter(w, flusher)
+	tailStream(r.Context(), sse, buf, lastID, streamID, h.heartbeatInterval())
 }
 
-func writeSSEMessage(w http.ResponseWriter, flusher http.Flusher, chunk models.ChatResponse) error {
-	chunkJSON, err := json.Marshal(chunk)
-	if err != nil {
-		return fmt.Errorf("error marshaling chunk: %v", err)
+// tailStream writes buffered events after lastID to the client and follows
+// the buffer until the stream finishes or the client goes away. While no
+// events arrive, a comment is sent every heartbeat to keep proxies from
+// closing the idle connection.
+func tailStream(ctx context.Context, sse *sseWriter, buf *streamBuffer, lastID int, requestID string, heartbeat time.Duration) {
+	defer buf.Attach()()
+
+	var ticker *time.Ticker
+	var ping <-chan time.Time
+	if heartbeat > 0 {
+		ticker = time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		ping = ticker.C
 	}
-	
-	_, err = fmt.Fprintf(w, "data: %s\n\n", chunkJSON)
-	if err != nil {
-		return fmt.Errorf("error writing to response: %v", err)
+
+	for {
+		events, done, wait := buf.Since(lastID)
+		for _, chunk := range events {
+			lastID++
+			if err := sse.Send(lastID, chunk); err != nil {
+				logger.LogError(requestID, err, "Failed to write stream event")
+				return
+			}
+		}
+		// Pings are only needed while nothing else is written
+		if ticker != nil && len(events) > 0 {
+			ticker.Reset(heartbeat)
+		}
+		if done {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.LogInfo(fmt.Sprintf("[%s] Client disconnected", requestID))
+			sse.Send(0, streamErrorChunk(requestID, context.Canceled))
+			return
+		case <-ping:
+			if err := sse.Comment("ping"); err != nil {
+				logger.LogError(requestID, err, "Failed to write heartbeat")
+				return
+			}
+		case <-wait:
+		}
 	}
-	
-	flusher.Flush()
-	return nil
-} 
\ No newline at end of file
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	apierrors "golang-ai-stream/errors"
+	"golang-ai-stream/logger"
+	"golang-ai-stream/models"
+)
+
+// wantsJSON reports whether the caller asked for a single JSON response
+// instead of an SSE stream
+func wantsJSON(r *http.Request, reqBody *models.ChatRequest) bool {
+	if reqBody.Stream != nil && !*reqBody.Stream {
+		return true
+	}
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/event-stream")
+}
+
+// respondJSON serves /chat in non-streaming mode. The generation runs
+// through the same pumpStream loop as SSE, into a private buffer whose
+// events are then aggregated into one ChatResponse.
+func (h *ChatHandler) respondJSON(w http.ResponseWriter, r *http.Request, stream ChatCompletionStreamer, job *streamJob) {
+	buf := newStreamBuffer()
+	h.pumpStream(r.Context(), stream, buf, job)
+	buf.Close()
+	events, _, _ := buf.Since(0)
+
+	response, failure := aggregateEvents(events, job)
+	if failure != nil {
+		logger.LogError(job.requestID, errors.New(failure.Content), "Chat completion failed")
+		jsonStreamError(failure, job).WithRequestID(job.requestID).RespondWithError(w)
+		return
+	}
+	if response == nil {
+		logger.LogInfo(fmt.Sprintf("[%s] Client disconnected", job.requestID))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// aggregateEvents folds the events of a finished generation into the JSON
+// mode response. It returns the first error event instead when the
+// generation failed, and neither when it was cut short by a disconnect.
+func aggregateEvents(events []models.ChatResponse, job *streamJob) (*models.ChatResponse, *models.ChatResponse) {
+	contents := map[int]string{}
+	var thinking strings.Builder
+	var usage *models.Usage
+	var choices []models.Choice
+	var first *models.ChatResponse
+
+	for i := range events {
+		event := &events[i]
+		index := 0
+		if event.Index != nil {
+			index = *event.Index
+		}
+		switch event.Type {
+		case "content":
+			contents[index] += event.Content
+		case "reasoning":
+			if index == 0 {
+				thinking.WriteString(event.Content)
+			}
+		case "tool_result", "repair":
+			// The reply after server tools or a repair replaces the earlier text
+			contents[0] = ""
+		case "usage":
+			usage = event.Usage
+		case "error":
+			return nil, event
+		case "done":
+			if first == nil {
+				first = event
+			}
+			choices = append(choices, models.Choice{
+				Index:        index,
+				Content:      contents[index],
+				FinishReason: event.FinishReason,
+				ToolCalls:    event.ToolCalls,
+			})
+		}
+	}
+	if first == nil {
+		return nil, nil
+	}
+
+	response := models.ChatResponse{
+		Content:        choices[0].Content,
+		RequestID:      job.requestID,
+		Type:           "done",
+		Meta:           first.Meta,
+		Usage:          usage,
+		FinishReason:   choices[0].FinishReason,
+		ToolCalls:      choices[0].ToolCalls,
+		ConversationID: first.ConversationID,
+		MessageID:      first.MessageID,
+		ParentID:       first.ParentID,
+		Context:        job.contextTrim,
+	}
+	// With n > 1 every alternative is in choices and the top-level fields
+	// mirror the first one
+	if job.chatReq.N > 1 {
+		response.Choices = choices
+	} else {
+		response.Reasoning = thinking.String()
+	}
+	return &response, nil
+}
+
+// jsonStreamError maps an error event to the HTTP error of a JSON mode
+// response
+func jsonStreamError(event *models.ChatResponse, job *streamJob) *apierrors.APIError {
+	message := event.Content
+	if event.Error.ErrorType == apierrors.TypeSchemaValidation && job.chatReq.N > 1 && event.Index != nil {
+		message = fmt.Sprintf("choice %d: %s", *event.Index, message)
+	}
+	return classifiedUpstreamError(event.Error.Code, event.Error.ErrorType, message)
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	apierrors "golang-ai-stream/errors"
+	"golang-ai-stream/models"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWantsJSON(t *testing.T) {
+	streamOff := false
+	streamOn := true
+
+	tests := []struct {
+		name   string
+		accept string
+		stream *bool
+		want   bool
+	}{
+		{name: "default", want: false},
+		{name: "event stream accept", accept: "text/event-stream", want: false},
+		{name: "json accept", accept: "application/json", want: true},
+		{name: "stream false", stream: &streamOff, want: true},
+		{name: "stream true with json accept", accept: "application/json", stream: &streamOn, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/chat", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			require.Equal(t, tt.want, wantsJSON(req, &models.ChatRequest{Stream: tt.stream}))
+		})
+	}
+}
+
+func TestHandleChat_JSONMode(t *testing.T) {
+	streamOff := false
+
+	t.Run("aggregates stream", func(t *testing.T) {
+		client := &scriptedClient{streams: []*scriptedStream{{chunks: []openai.ChatCompletionStreamResponse{
+			contentChunk(0, "Hel", ""),
+			contentChunk(0, "lo", openai.FinishReasonStop),
+		}}}}
+		handler := NewChatHandler(client, testConfig(), nil)
+
+		w := httptest.NewRecorder()
+		handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Prompt: "hi", Stream: &streamOff}))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var response models.ChatResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+		require.Equal(t, "Hello", response.Content)
+		require.Equal(t, "done", response.Type)
+		require.Equal(t, "stop", response.FinishReason)
+		require.Equal(t, "test-id", response.RequestID)
+		require.NotNil(t, response.Usage)
+		require.True(t, response.Usage.Estimated)
+	})
+
+	tests := []struct {
+		name       string
+		body       models.ChatRequest
+		client     *scriptedClient
+		wantStatus int
+		wantMsg    string
+	}{
+		{
+			name:       "validation error",
+			body:       models.ChatRequest{Stream: &streamOff},
+			client:     &scriptedClient{},
+			wantStatus: http.StatusBadRequest,
+			wantMsg:    "prompt cannot be empty",
+		},
+		{
+			name:       "upstream creation error",
+			body:       models.ChatRequest{Prompt: "hi", Stream: &streamOff},
+			client:     &scriptedClient{err: fmt.Errorf("upstream down")},
+			wantStatus: http.StatusBadGateway,
+			wantMsg:    "Failed to create chat completion stream",
+		},
+		{
+			name:       "upstream receive error",
+			body:       models.ChatRequest{Prompt: "hi", Stream: &streamOff},
+			client:     &scriptedClient{streams: []*scriptedStream{{err: fmt.Errorf("broken")}}},
+			wantStatus: http.StatusBadGateway,
+			wantMsg:    "Failed to receive chat completion",
+		},
+		{
+			name:       "upstream rate limited mid-stream",
+			body:       models.ChatRequest{Prompt: "hi", Stream: &streamOff},
+			client:     &scriptedClient{streams: []*scriptedStream{{err: &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests}}}},
+			wantStatus: http.StatusServiceUnavailable,
+			wantMsg:    "Upstream provider rate limit exceeded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewChatHandler(tt.client, testConfig(), nil)
+			w := httptest.NewRecorder()
+			handler.HandleChat(w, newTestRequest(t, "/chat", tt.body))
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			var response apierrors.APIError
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+			require.Equal(t, tt.wantMsg, response.Message)
+			require.Equal(t, "test-id", response.RequestID)
+		})
+	}
+}
+
+func TestHandleChat_JSONModeAutoContinue(t *testing.T) {
+	streamOff := false
+	client := &scriptedClient{streams: []*scriptedStream{
+		{chunks: []openai.ChatCompletionStreamResponse{contentChunk(0, "Once upon", openai.FinishReasonLength)}},
+		{chunks: []openai.ChatCompletionStreamResponse{contentChunk(0, " a time", openai.FinishReasonStop)}},
+	}}
+	cfg := testConfig()
+	cfg.MaxContinuations = 2
+	handler := NewChatHandler(client, cfg, nil)
+
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Prompt: "tell a story", Stream: &streamOff, AutoContinue: true}))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var response models.ChatResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	require.Equal(t, "Once upon a time", response.Content)
+	require.Equal(t, "stop", response.FinishReason)
+
+	require.Len(t, client.reqs, 2)
+	followUp := client.reqs[1].Messages
+	require.Len(t, followUp, 3)
+	require.Equal(t, "Once upon", followUp[1].Content)
+	require.Equal(t, continuePrompt, followUp[2].Content)
+}
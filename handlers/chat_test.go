@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"golang-ai-stream/config"
+	apierrors "golang-ai-stream/errors"
 	"golang-ai-stream/middleware"
 	"golang-ai-stream/models"
 
@@ -63,9 +64,11 @@ func (m *mockStream) Close() {
 type mockClient struct {
 	err    error
 	stream *mockStream
+	req    openai.ChatCompletionRequest
 }
 
 func (m *mockClient) CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (ChatCompletionStreamer, error) {
+	m.req = req
 	if m.err != nil {
 		return nil, m.err
 	}
@@ -96,19 +99,24 @@ func TestChatHandler_HandleChat(t *testing.T) {
 	tests := []struct {
 		name           string
 		prompt        string
+		messages      []models.Message
+		model         string
 		setupClient   func(*mockClient)
 		cancelContext bool
+		wantStatus    int
 		wantCount     int
 		wantTypes     []string
 		wantContent   string
+		wantErrorType string
 	}{
 		{
 			name:   "client_disconnect",
 			prompt: "test",
 			cancelContext: true,
-			wantCount: 1,
-			wantTypes: []string{"error"},
+			wantCount: 2,
+			wantTypes: []string{"connected", "error"},
 			wantContent: "Client disconnected",
+			wantErrorType: "client_disconnected",
 		},
 		{
 			name:   "stream_error",
@@ -116,9 +124,21 @@ func TestChatHandler_HandleChat(t *testing.T) {
 			setupClient: func(m *mockClient) {
 				m.stream = &mockStream{err: fmt.Errorf("stream error")}
 			},
-			wantCount: 1,
-			wantTypes: []string{"error"},
-			wantContent: "Failed to create chat completion stream",
+			wantCount: 2,
+			wantTypes: []string{"connected", "error"},
+			wantContent: "Failed to receive chat completion",
+			wantErrorType: "upstream_error",
+		},
+		{
+			name:   "stream_rate_limited",
+			prompt: "test",
+			setupClient: func(m *mockClient) {
+				m.stream = &mockStream{err: &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests, Message: "slow down"}}
+			},
+			wantCount: 2,
+			wantTypes: []string{"connected", "error"},
+			wantContent: "Upstream provider rate limit exceeded",
+			wantErrorType: "rate_limited",
 		},
 		{
 			name:   "client_error",
@@ -126,24 +146,62 @@ func TestChatHandler_HandleChat(t *testing.T) {
 			setupClient: func(m *mockClient) {
 				m.err = fmt.Errorf("client error")
 			},
-			wantCount: 1,
-			wantTypes: []string{"error"},
+			wantStatus: http.StatusBadGateway,
 			wantContent: "Failed to create chat completion stream",
 		},
 		{
 			name:   "empty_prompt",
 			prompt: "",
-			wantCount: 1,
-			wantTypes: []string{"error"},
+			wantStatus: http.StatusBadRequest,
 			wantContent: "prompt cannot be empty",
 		},
 		{
 			name:   "long_prompt",
 			prompt: strings.Repeat("a", 101),
-			wantCount: 1,
-			wantTypes: []string{"error"},
+			wantStatus: http.StatusRequestEntityTooLarge,
 			wantContent: "prompt exceeds maximum length of 100 characters",
 		},
+		{
+			name: "invalid_message_role",
+			messages: []models.Message{{Role: "narrator", Content: "hello"}},
+			wantStatus: http.StatusBadRequest,
+			wantContent: `message 0 has invalid role "narrator"`,
+		},
+		{
+			name: "tool_result_without_id",
+			messages: []models.Message{{Role: "tool", Content: "42"}},
+			wantStatus: http.StatusBadRequest,
+			wantContent: "message 0 tool result requires tool_call_id",
+		},
+		{
+			name: "empty_message_content",
+			messages: []models.Message{{Role: "user", Content: "  "}},
+			wantStatus: http.StatusBadRequest,
+			wantContent: "message 0 content cannot be empty",
+		},
+		{
+			name: "long_message",
+			messages: []models.Message{{Role: "user", Content: strings.Repeat("a", 101)}},
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantContent: "message 0 exceeds maximum length of 100 characters",
+		},
+		{
+			name: "long_conversation",
+			messages: []models.Message{
+				{Role: "system", Content: strings.Repeat("a", 100)},
+				{Role: "user", Content: strings.Repeat("b", 100)},
+			},
+			prompt: strings.Repeat("c", 100),
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantContent: "conversation exceeds maximum total length of 250 characters",
+		},
+		{
+			name:   "model_not_allowed",
+			prompt: "test",
+			model:  "other/model",
+			wantStatus: http.StatusBadRequest,
+			wantContent: `model "other/model" is not allowed`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -153,9 +211,9 @@ func TestChatHandler_HandleChat(t *testing.T) {
 				tt.setupClient(client)
 			}
 
-			handler := NewChatHandler(client, &config.Config{MaxPromptLength: 100})
+			handler := NewChatHandler(client, &config.Config{MaxPromptLength: 100, MaxTotalLength: 250}, nil)
 
-			reqBody := models.ChatRequest{Prompt: tt.prompt}
+			reqBody := models.ChatRequest{Prompt: tt.prompt, Messages: tt.messages, Model: tt.model}
 			body, _ := json.Marshal(reqBody)
 			req := httptest.NewRequest(http.MethodPost, "/chat", bytes.NewReader(body))
 			req = req.WithContext(context.WithValue(context.Background(), middleware.RequestIDKey, uuid.New().String()))
@@ -179,6 +237,14 @@ func TestChatHandler_HandleChat(t *testing.T) {
 
 			<-done
 
+			if tt.wantStatus != 0 {
+				require.Equal(t, tt.wantStatus, w.Code)
+				var apiErr apierrors.APIError
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&apiErr))
+				require.Equal(t, tt.wantContent, apiErr.Message)
+				return
+			}
+
 			responses := collectResponses(t, w)
 			require.Equal(t, tt.wantCount, len(responses), "response count mismatch")
 			
@@ -196,6 +262,102 @@ func TestChatHandler_HandleChat(t *testing.T) {
 				}
 				require.True(t, found, "expected content %q not found in responses", tt.wantContent)
 			}
+
+			if tt.wantErrorType != "" {
+				last := responses[len(responses)-1]
+				require.NotNil(t, last.Error)
+				require.Equal(t, tt.wantErrorType, last.Error.ErrorType)
+			}
 		})
 	}
-} 
\ No newline at end of file
+} 
+
+func TestChatHandler_ConnectedEvent(t *testing.T) {
+	client := &scriptedClient{streams: []*scriptedStream{{chunks: []openai.ChatCompletionStreamResponse{
+		contentChunk(0, "Hi", openai.FinishReasonStop),
+	}}}}
+	cfg := testConfig()
+	cfg.StreamBufferSecs = 60
+	handler := NewChatHandler(client, cfg, nil)
+
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Prompt: "hi"}))
+
+	responses := collectResponses(t, w)
+	require.Len(t, responses, 4)
+	connected := responses[0]
+	require.Equal(t, "connected", connected.Type)
+	require.Equal(t, "test-id", connected.RequestID)
+	require.Equal(t, "test/model", connected.Meta.Model)
+	require.True(t, client.reqs[0].StreamOptions.IncludeUsage)
+	require.Equal(t, &models.StreamLimits{
+		MaxPromptLength:  100,
+		MaxTotalLength:   250,
+		MaxTokens:        1000,
+		ResumeWindowSecs: 60,
+	}, connected.Meta.Limits)
+	require.Equal(t, "content", responses[1].Type)
+	require.Equal(t, "usage", responses[2].Type)
+	require.Equal(t, "done", responses[3].Type)
+}
+
+func TestTailStream_Heartbeat(t *testing.T) {
+	buf := newStreamBuffer()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		buf.Append(models.ChatResponse{Type: "done"})
+		buf.Close()
+	}()
+
+	w := httptest.NewRecorder()
+	tailStream(context.Background(), newSSEWriter(w, w, 0), buf, 0, "test-id", 10*time.Millisecond)
+
+	body := w.Body.String()
+	require.Contains(t, body, ": ping\n\n")
+	require.True(t, strings.HasSuffix(body, "event: done\ndata: {\"content\":\"\",\"request_id\":\"\",\"type\":\"done\"}\n\n"))
+}
+
+func TestTailStream_NoHeartbeatWhileStreaming(t *testing.T) {
+	buf := newStreamBuffer()
+	go func() {
+		for i := 0; i < 10; i++ {
+			time.Sleep(10 * time.Millisecond)
+			buf.Append(models.ChatResponse{Type: "content"})
+		}
+		buf.Append(models.ChatResponse{Type: "done"})
+		buf.Close()
+	}()
+
+	w := httptest.NewRecorder()
+	tailStream(context.Background(), newSSEWriter(w, w, 0), buf, 0, "test-id", 40*time.Millisecond)
+
+	require.NotContains(t, w.Body.String(), ": ping")
+}
+
+func TestBuildMessages(t *testing.T) {
+	reqBody := &models.ChatRequest{
+		Messages: []models.Message{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: "hi"},
+			{Role: "assistant", Content: "hello"},
+		},
+		Prompt: "how are you?",
+	}
+
+	messages := buildMessages(reqBody)
+	require.Len(t, messages, 4)
+	require.Equal(t, openai.ChatMessageRoleSystem, messages[0].Role)
+	require.Equal(t, openai.ChatMessageRoleAssistant, messages[2].Role)
+	require.Equal(t, openai.ChatMessageRoleUser, messages[3].Role)
+	require.Equal(t, "how are you?", messages[3].Content)
+}
+
+func TestChatHandler_ResolveModel(t *testing.T) {
+	handler := NewChatHandler(&mockClient{}, &config.Config{
+		DefaultModel:  "default/model",
+		AllowedModels: []string{"allowed/model"},
+	}, nil)
+
+	require.Equal(t, "default/model", handler.resolveModel(&models.ChatRequest{}))
+	require.Equal(t, "allowed/model", handler.resolveModel(&models.ChatRequest{Model: "allowed/model"}))
+}
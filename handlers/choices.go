@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"fmt"
+
+	"golang-ai-stream/models"
+)
+
+// choiceCount returns the number of completions requested
+func choiceCount(reqBody *models.ChatRequest) int {
+	if reqBody.N == nil {
+		return 1
+	}
+	return *reqBody.N
+}
+
+// validateChoices bounds n and rejects the features that extend a single
+// conversation, which have no meaning for several alternatives
+func validateChoices(reqBody *models.ChatRequest, maxChoices int) error {
+	n := choiceCount(reqBody)
+	if err := validateChoiceCount(n, maxChoices); err != nil {
+		return err
+	}
+	if n == 1 {
+		return nil
+	}
+	if reqBody.AutoContinue {
+		return fmt.Errorf("auto_continue cannot be combined with n > 1")
+	}
+	if reqBody.ResponseFormat != nil && reqBody.ResponseFormat.Repair {
+		return fmt.Errorf("response_format repair cannot be combined with n > 1")
+	}
+	return nil
+}
+
+func validateChoiceCount(n, maxChoices int) error {
+	if n < 1 {
+		return fmt.Errorf("n must be positive")
+	}
+	if maxChoices > 0 && n > maxChoices {
+		return fmt.Errorf("n exceeds limit of %d", maxChoices)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"golang-ai-stream/models"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateChoices(t *testing.T) {
+	tests := []struct {
+		name    string
+		reqBody models.ChatRequest
+		wantErr string
+	}{
+		{name: "default"},
+		{name: "several", reqBody: models.ChatRequest{N: intPtr(3)}},
+		{name: "zero", reqBody: models.ChatRequest{N: intPtr(0)}, wantErr: "n must be positive"},
+		{name: "over limit", reqBody: models.ChatRequest{N: intPtr(5)}, wantErr: "n exceeds limit of 4"},
+		{name: "auto continue", reqBody: models.ChatRequest{N: intPtr(2), AutoContinue: true}, wantErr: "auto_continue cannot be combined with n > 1"},
+		{
+			name:    "repair",
+			reqBody: models.ChatRequest{N: intPtr(2), ResponseFormat: &models.ResponseFormat{Type: "json_object", Repair: true}},
+			wantErr: "response_format repair cannot be combined with n > 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateChoices(&tt.reqBody, 4)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestHandleChat_MultipleChoices(t *testing.T) {
+	client := &scriptedClient{streams: []*scriptedStream{{chunks: []openai.ChatCompletionStreamResponse{
+		contentChunk(0, "Dear", ""),
+		contentChunk(1, "Hi", ""),
+		{}, // chunks without choices must not break the stream
+		contentChunk(1, " there", openai.FinishReasonStop),
+		contentChunk(0, " Sir", openai.FinishReasonLength),
+	}}}}
+	handler := NewChatHandler(client, testConfig(), weatherRegistry(t))
+
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Prompt: "greet", N: intPtr(2)}))
+
+	require.Equal(t, 2, client.reqs[0].N)
+	require.Empty(t, client.reqs[0].Tools, "server tools are not offered to several choices")
+
+	content := map[int]string{}
+	var done []models.ChatResponse
+	responses := collectResponses(t, w)
+	for _, resp := range responses {
+		switch resp.Type {
+		case "content":
+			require.NotNil(t, resp.Index)
+			content[*resp.Index] += resp.Content
+		case "done":
+			done = append(done, resp)
+		}
+	}
+	require.Equal(t, map[int]string{0: "Dear Sir", 1: "Hi there"}, content)
+	require.Len(t, done, 2)
+	require.Equal(t, 0, *done[0].Index)
+	require.Equal(t, "length", done[0].FinishReason)
+	require.Equal(t, 1, *done[1].Index)
+	require.Equal(t, "stop", done[1].FinishReason)
+	require.Equal(t, "done", responses[len(responses)-1].Type)
+}
+
+func TestHandleChat_EmptyChoices(t *testing.T) {
+	client := &scriptedClient{streams: []*scriptedStream{{chunks: []openai.ChatCompletionStreamResponse{{}}}}}
+	handler := NewChatHandler(client, testConfig(), nil)
+
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Prompt: "hi"}))
+
+	responses := collectResponses(t, w)
+	last := responses[len(responses)-1]
+	require.Equal(t, "done", last.Type)
+	require.Equal(t, 0, *last.Index)
+}
+
+func TestHandleChat_MultipleChoicesJSON(t *testing.T) {
+	client := &scriptedClient{streams: []*scriptedStream{{chunks: []openai.ChatCompletionStreamResponse{
+		contentChunk(0, "Dear Sir", openai.FinishReasonStop),
+		contentChunk(1, "Hi there", openai.FinishReasonStop),
+	}}}}
+	handler := NewChatHandler(client, testConfig(), nil)
+
+	stream := false
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Prompt: "greet", N: intPtr(2), Stream: &stream}))
+
+	var response models.ChatResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	require.Equal(t, "Dear Sir", response.Content)
+	require.Equal(t, []models.Choice{
+		{Index: 0, Content: "Dear Sir", FinishReason: "stop"},
+		{Index: 1, Content: "Hi there", FinishReason: "stop"},
+	}, response.Choices)
+}
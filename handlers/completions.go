@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	apierrors "golang-ai-stream/errors"
+	"golang-ai-stream/logger"
+	"golang-ai-stream/middleware"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// HandleChatCompletions serves an OpenAI-compatible /v1/chat/completions
+// endpoint on top of the same upstream client used by /chat
+func (h *ChatHandler) HandleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(middleware.RequestIDKey).(string)
+
+	var chatReq openai.ChatCompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&chatReq); err != nil {
+		logger.LogError(requestID, err, "Invalid request payload")
+		writeOpenAIError(w, http.StatusBadRequest, "Invalid request payload", "invalid_request_error")
+		return
+	}
+
+	if err := h.validateCompletionRequest(&chatReq); err != nil {
+		logger.LogError(requestID, err, "Request validation failed")
+		writeAPIErrorAsOpenAI(w, validationError(err))
+		return
+	}
+
+	stream := chatReq.Stream
+	chatReq.Stream = true
+	// Aggregated responses report usage like a non-streaming upstream call;
+	// streaming clients opt in through their own stream_options
+	if !stream {
+		chatReq.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	}
+
+	logger.LogRequest(logger.INFO, requestID, r.Method, r.URL.Path, http.StatusOK, 0,
+		fmt.Sprintf("Processing chat completion for model %s with %d messages", chatReq.Model, len(chatReq.Messages)))
+
+	upstream, err := h.client.CreateChatCompletionStream(r.Context(), chatReq)
+	if err != nil {
+		logger.LogError(requestID, err, "Error creating chat completion stream")
+		writeAPIErrorAsOpenAI(w, upstreamError(err, "Failed to create chat completion stream"))
+		return
+	}
+	defer upstream.Close()
+
+	if stream {
+		h.streamCompletionChunks(w, r, upstream, requestID)
+		return
+	}
+
+	response, err := aggregateCompletion(upstream, max(chatReq.N, 1))
+	if err != nil {
+		logger.LogError(requestID, err, "Failed to receive chat completion")
+		writeAPIErrorAsOpenAI(w, upstreamError(err, "Failed to receive chat completion"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *ChatHandler) validateCompletionRequest(chatReq *openai.ChatCompletionRequest) error {
+	if chatReq.Model == "" {
+		chatReq.Model = h.config.DefaultModel
+	} else if !h.config.IsModelAllowed(chatReq.Model) {
+		return fmt.Errorf("model %q is not allowed", chatReq.Model)
+	}
+	if len(chatReq.Messages) == 0 {
+		return fmt.Errorf("messages cannot be empty")
+	}
+	total := 0
+	for i, msg := range chatReq.Messages {
+		size := len(msg.Content)
+		for j, part := range msg.MultiContent {
+			size += len(part.Text)
+			if part.ImageURL != nil {
+				if err := h.validateImageURL(part.ImageURL.URL); err != nil {
+					return fmt.Errorf("message %d part %d: %w", i, j, err)
+				}
+			}
+		}
+		if size > h.config.MaxPromptLength {
+			return tooLargeError{fmt.Errorf("message %d exceeds maximum length of %d characters", i, h.config.MaxPromptLength)}
+		}
+		total += size
+	}
+	if h.config.MaxTotalLength > 0 && total > h.config.MaxTotalLength {
+		return tooLargeError{fmt.Errorf("conversation exceeds maximum total length of %d characters", h.config.MaxTotalLength)}
+	}
+	// n is omitted when zero, which upstream treats as a single choice
+	if chatReq.N != 0 {
+		if err := validateChoiceCount(chatReq.N, h.config.MaxChoices); err != nil {
+			return err
+		}
+	}
+	return validateUpstreamSampling(chatReq, h.config.MaxTokensLimit)
+}
+
+// streamCompletionChunks relays upstream chunks unchanged as OpenAI-style SSE
+// frames, terminated by the [DONE] sentinel
+func (h *ChatHandler) streamCompletionChunks(w http.ResponseWriter, r *http.Request, upstream ChatCompletionStreamer, requestID string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeOpenAIError(w, http.StatusInternalServerError, "Streaming unsupported", "server_error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	for {
+		response, err := upstream.Recv()
+		if err == io.EOF {
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			flusher.Flush()
+			return
+		}
+		if err != nil {
+			if errors.Is(err, context.Canceled) || r.Context().Err() != nil {
+				logger.LogInfo(fmt.Sprintf("[%s] Client disconnected", requestID))
+				return
+			}
+			logger.LogError(requestID, err, "Failed to receive chat completion")
+			payload, _ := json.Marshal(openai.ErrorResponse{Error: &openai.APIError{
+				Message: streamErrorMessages[classifyError(err)],
+				Type:    classifyError(err),
+			}})
+			fmt.Fprintf(w, "data: %s\n\n", payload)
+			flusher.Flush()
+			return
+		}
+
+		if response.Object == "" {
+			response.Object = "chat.completion.chunk"
+		}
+		payload, err := json.Marshal(response)
+		if err != nil {
+			logger.LogError(requestID, err, "Error marshaling chunk")
+			return
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", payload); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// aggregateCompletion drains the stream into a single non-streaming response
+// with up to n choices; chunks for other choice indexes are ignored
+func aggregateCompletion(upstream ChatCompletionStreamer, n int) (*openai.ChatCompletionResponse, error) {
+	response := &openai.ChatCompletionResponse{Object: "chat.completion"}
+	contents := map[int]string{}
+	toolCalls := map[int]*toolCallAssembler{}
+	var choices []openai.ChatCompletionChoice
+
+	for {
+		chunk, err := upstream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if response.ID == "" {
+			response.ID = chunk.ID
+			response.Created = chunk.Created
+			response.Model = chunk.Model
+			response.SystemFingerprint = chunk.SystemFingerprint
+		}
+		if chunk.Usage != nil {
+			response.Usage = *chunk.Usage
+		}
+
+		for _, choice := range chunk.Choices {
+			if choice.Index < 0 || choice.Index >= n {
+				continue
+			}
+			for len(choices) <= choice.Index {
+				choices = append(choices, openai.ChatCompletionChoice{
+					Index:   len(choices),
+					Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant},
+				})
+			}
+			contents[choice.Index] += choice.Delta.Content
+			if len(choice.Delta.ToolCalls) > 0 {
+				if toolCalls[choice.Index] == nil {
+					toolCalls[choice.Index] = newToolCallAssembler()
+				}
+				toolCalls[choice.Index].Add(choice.Delta.ToolCalls)
+			}
+			if choice.FinishReason != "" {
+				choices[choice.Index].FinishReason = choice.FinishReason
+			}
+		}
+	}
+
+	for i := range choices {
+		choices[i].Message.Content = contents[i]
+		if assembler := toolCalls[i]; assembler != nil {
+			choices[i].Message.ToolCalls = toOpenAIToolCalls(assembler.Calls())
+		}
+	}
+	response.Choices = choices
+	if response.Created == 0 {
+		response.Created = time.Now().Unix()
+	}
+	return response, nil
+}
+
+// writeAPIErrorAsOpenAI sends an API error with its status code in the
+// OpenAI error format. Request errors use OpenAI's invalid_request_error
+// type; classified upstream failures keep their specific error type.
+func writeAPIErrorAsOpenAI(w http.ResponseWriter, apiErr *apierrors.APIError) {
+	errType := apiErr.ErrorType
+	switch errType {
+	case apierrors.TypeBadRequest, apierrors.TypePayloadTooLarge:
+		errType = "invalid_request_error"
+	case apierrors.TypeBadGateway, apierrors.TypeServiceUnavailable:
+		errType = apierrors.TypeUpstreamError
+	}
+	writeOpenAIError(w, apiErr.Code, apiErr.Message, errType)
+}
+
+func writeOpenAIError(w http.ResponseWriter, status int, message, errType string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(openai.ErrorResponse{Error: &openai.APIError{
+		Message: message,
+		Type:    errType,
+	}})
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang-ai-stream/config"
+	"golang-ai-stream/middleware"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedStream replays a fixed list of chunks followed by io.EOF
+type scriptedStream struct {
+	chunks []openai.ChatCompletionStreamResponse
+	err    error
+	closed bool
+}
+
+func (s *scriptedStream) Recv() (*openai.ChatCompletionStreamResponse, error) {
+	if len(s.chunks) == 0 {
+		if s.err != nil {
+			return nil, s.err
+		}
+		return nil, io.EOF
+	}
+	chunk := s.chunks[0]
+	s.chunks = s.chunks[1:]
+	return &chunk, nil
+}
+
+func (s *scriptedStream) Close() {
+	s.closed = true
+}
+
+type scriptedClient struct {
+	err     error
+	streams []*scriptedStream
+	reqs    []openai.ChatCompletionRequest
+}
+
+func (c *scriptedClient) CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (ChatCompletionStreamer, error) {
+	c.reqs = append(c.reqs, req)
+	if c.err != nil {
+		return nil, c.err
+	}
+	if len(c.streams) == 0 {
+		return &scriptedStream{}, nil
+	}
+	stream := c.streams[0]
+	c.streams = c.streams[1:]
+	return stream, nil
+}
+
+func contentChunk(index int, content string, finishReason openai.FinishReason) openai.ChatCompletionStreamResponse {
+	return openai.ChatCompletionStreamResponse{
+		ID:    "chatcmpl-test",
+		Model: "test/model",
+		Choices: []openai.ChatCompletionStreamChoice{{
+			Index:        index,
+			Delta:        openai.ChatCompletionStreamChoiceDelta{Content: content},
+			FinishReason: finishReason,
+		}},
+	}
+}
+
+func newTestRequest(t *testing.T, path string, body any) *http.Request {
+	payload, err := json.Marshal(body)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(payload))
+	return req.WithContext(context.WithValue(req.Context(), middleware.RequestIDKey, "test-id"))
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		MaxPromptLength:   100,
+		MaxTotalLength:    250,
+		MaxTokensLimit:    1000,
+		DefaultModel:      "test/model",
+		MaxToolIterations: 3,
+		MaxChoices:        4,
+	}
+}
+
+func TestHandleChatCompletions_Streaming(t *testing.T) {
+	client := &scriptedClient{streams: []*scriptedStream{{chunks: []openai.ChatCompletionStreamResponse{
+		contentChunk(0, "Hel", ""),
+		contentChunk(0, "lo", openai.FinishReasonStop),
+	}}}}
+	handler := NewChatHandler(client, testConfig(), nil)
+
+	req := newTestRequest(t, "/v1/chat/completions", openai.ChatCompletionRequest{
+		Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}},
+		Stream:   true,
+	})
+	w := httptest.NewRecorder()
+	handler.HandleChatCompletions(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	require.Equal(t, "test/model", client.reqs[0].Model)
+
+	frames := strings.Split(strings.TrimSpace(w.Body.String()), "\n\n")
+	require.Len(t, frames, 3)
+	require.Equal(t, "data: [DONE]", frames[2])
+
+	var chunk openai.ChatCompletionStreamResponse
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(frames[0], "data: ")), &chunk))
+	require.Equal(t, "chat.completion.chunk", chunk.Object)
+	require.Equal(t, "Hel", chunk.Choices[0].Delta.Content)
+}
+
+func TestHandleChatCompletions_NonStreaming(t *testing.T) {
+	client := &scriptedClient{streams: []*scriptedStream{{chunks: []openai.ChatCompletionStreamResponse{
+		contentChunk(0, "Hel", ""),
+		contentChunk(0, "lo", openai.FinishReasonStop),
+		{Usage: &openai.Usage{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5}},
+	}}}}
+	handler := NewChatHandler(client, testConfig(), nil)
+
+	req := newTestRequest(t, "/v1/chat/completions", openai.ChatCompletionRequest{
+		Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}},
+	})
+	w := httptest.NewRecorder()
+	handler.HandleChatCompletions(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.True(t, client.reqs[0].Stream, "upstream request should always stream")
+	require.True(t, client.reqs[0].StreamOptions.IncludeUsage)
+
+	var response openai.ChatCompletionResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	require.Equal(t, "chat.completion", response.Object)
+	require.Equal(t, "chatcmpl-test", response.ID)
+	require.Len(t, response.Choices, 1)
+	require.Equal(t, "Hello", response.Choices[0].Message.Content)
+	require.Equal(t, openai.FinishReasonStop, response.Choices[0].FinishReason)
+	require.Equal(t, 5, response.Usage.TotalTokens)
+}
+
+func TestHandleChatCompletions_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       any
+		clientErr  error
+		wantStatus int
+		wantMsg    string
+		wantType   string
+	}{
+		{
+			name:       "invalid payload",
+			body:       "not an object",
+			wantStatus: http.StatusBadRequest,
+			wantMsg:    "Invalid request payload",
+		},
+		{
+			name:       "empty messages",
+			body:       openai.ChatCompletionRequest{},
+			wantStatus: http.StatusBadRequest,
+			wantMsg:    "messages cannot be empty",
+		},
+		{
+			name: "model not allowed",
+			body: openai.ChatCompletionRequest{
+				Model:    "other/model",
+				Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}},
+			},
+			wantStatus: http.StatusBadRequest,
+			wantMsg:    `model "other/model" is not allowed`,
+		},
+		{
+			name: "temperature out of range",
+			body: openai.ChatCompletionRequest{
+				Messages:    []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}},
+				Temperature: 5,
+			},
+			wantStatus: http.StatusBadRequest,
+			wantMsg:    "temperature must be between 0 and 2",
+		},
+		{
+			name: "too many stop sequences",
+			body: openai.ChatCompletionRequest{
+				Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}},
+				Stop:     []string{"a", "b", "c", "d", "e"},
+			},
+			wantStatus: http.StatusBadRequest,
+			wantMsg:    "at most 4 stop sequences are allowed",
+		},
+		{
+			name: "too many choices",
+			body: openai.ChatCompletionRequest{
+				Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}},
+				N:        100,
+			},
+			wantStatus: http.StatusBadRequest,
+			wantMsg:    "n exceeds limit of 4",
+		},
+		{
+			name: "negative max tokens",
+			body: openai.ChatCompletionRequest{
+				Messages:  []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}},
+				MaxTokens: -1,
+			},
+			wantStatus: http.StatusBadRequest,
+			wantMsg:    "max_tokens must be positive",
+		},
+		{
+			name: "upstream failure",
+			body: openai.ChatCompletionRequest{
+				Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}},
+			},
+			clientErr:  fmt.Errorf("upstream down"),
+			wantStatus: http.StatusBadGateway,
+			wantMsg:    "Failed to create chat completion stream",
+			wantType:   "upstream_error",
+		},
+		{
+			name: "upstream rate limited",
+			body: openai.ChatCompletionRequest{
+				Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "hi"}},
+			},
+			clientErr:  &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests, Message: "slow down"},
+			wantStatus: http.StatusServiceUnavailable,
+			wantMsg:    "Failed to create chat completion stream",
+			wantType:   "rate_limited",
+		},
+		{
+			name: "message too long",
+			body: openai.ChatCompletionRequest{
+				Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: strings.Repeat("a", 101)}},
+			},
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantMsg:    "message 0 exceeds maximum length of 100 characters",
+			wantType:   "invalid_request_error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewChatHandler(&scriptedClient{err: tt.clientErr}, testConfig(), nil)
+			w := httptest.NewRecorder()
+			handler.HandleChatCompletions(w, newTestRequest(t, "/v1/chat/completions", tt.body))
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			var response openai.ErrorResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+			require.Equal(t, tt.wantMsg, response.Error.Message)
+			if tt.wantType != "" {
+				require.Equal(t, tt.wantType, response.Error.Type)
+			}
+		})
+	}
+}
+
+func TestAggregateCompletion_IgnoresOutOfRangeChoices(t *testing.T) {
+	stream := &scriptedStream{chunks: []openai.ChatCompletionStreamResponse{
+		contentChunk(0, "Hello", ""),
+		contentChunk(-1, "bad", openai.FinishReasonStop),
+		contentChunk(1_000_000, "huge", openai.FinishReasonStop),
+		contentChunk(0, "", openai.FinishReasonStop),
+	}}
+
+	response, err := aggregateCompletion(stream, 1)
+	require.NoError(t, err)
+	require.Len(t, response.Choices, 1)
+	require.Equal(t, "Hello", response.Choices[0].Message.Content)
+	require.Equal(t, openai.FinishReasonStop, response.Choices[0].FinishReason)
+}
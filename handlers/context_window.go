@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang-ai-stream/config"
+	"golang-ai-stream/logger"
+	"golang-ai-stream/models"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// summaryMaxTokens bounds the summary of trimmed turns; the same amount is
+// reserved in the context window for it
+const summaryMaxTokens = 512
+
+const summaryInstruction = "Summarize the following conversation in a few sentences. " +
+	"Keep names, facts, decisions and open questions that later turns may rely on."
+
+// fitContext trims the conversation when its estimated size plus the
+// completion budget exceeds the model context window. System messages and
+// the latest turn are always kept. It returns nil when nothing was trimmed.
+func (h *ChatHandler) fitContext(ctx context.Context, requestID string, chatReq *openai.ChatCompletionRequest) (*models.ContextTrim, error) {
+	window := h.config.ContextWindow(chatReq.Model)
+	if window <= 0 {
+		return nil, nil
+	}
+	budget := window - chatReq.MaxTokens
+	before := estimatePromptTokens(chatReq.Messages)
+	if before <= budget {
+		return nil, nil
+	}
+
+	strategy := h.config.ContextStrategy
+	keepLast := 0
+	switch strategy {
+	case config.ContextKeepLast:
+		keepLast = h.config.ContextKeepLast
+	case config.ContextSummarize:
+		budget -= summaryMaxTokens + tokensPerMessage
+	}
+	kept, dropped := trimMessages(chatReq.Messages, budget, keepLast)
+	if estimatePromptTokens(kept) > budget {
+		return nil, tooLargeError{fmt.Errorf("conversation exceeds the context window of %d tokens", window)}
+	}
+
+	trim := &models.ContextTrim{
+		Strategy:        strategy,
+		ContextWindow:   window,
+		DroppedMessages: len(dropped),
+		TokensBefore:    before,
+	}
+	if strategy == config.ContextSummarize && len(dropped) > 0 {
+		// Losing the summary only costs context, so fall back to dropping
+		if summary, err := h.summarize(ctx, chatReq.Model, dropped); err != nil {
+			logger.LogError(requestID, err, "Failed to summarize trimmed turns")
+		} else {
+			kept = insertSummary(kept, summary)
+			trim.Summarized = true
+		}
+	}
+	chatReq.Messages = kept
+	trim.TokensAfter = estimatePromptTokens(kept)
+	return trim, nil
+}
+
+// trimMessages drops the oldest non-system turns until the conversation fits
+// the budget, after first dropping all but the last keepLast of them when
+// keepLast is set. An assistant turn that calls tools is dropped together
+// with its tool results so the history stays valid for the upstream.
+func trimMessages(messages []openai.ChatCompletionMessage, budget, keepLast int) (kept, dropped []openai.ChatCompletionMessage) {
+	type span struct{ start, end int }
+	var turns []span
+	for i := 0; i < len(messages); {
+		end := i + 1
+		if len(messages[i].ToolCalls) > 0 {
+			for end < len(messages) && messages[end].Role == openai.ChatMessageRoleTool {
+				end++
+			}
+		}
+		if messages[i].Role != openai.ChatMessageRoleSystem {
+			turns = append(turns, span{i, end})
+		}
+		i = end
+	}
+
+	remaining := 0
+	for _, turn := range turns {
+		remaining += turn.end - turn.start
+	}
+	total := estimatePromptTokens(messages)
+	drop := make([]bool, len(messages))
+	// The latest turn is what the model has to answer, so it is never dropped
+	for _, turn := range turns[:max(len(turns)-1, 0)] {
+		if total <= budget && (keepLast <= 0 || remaining <= keepLast) {
+			break
+		}
+		for i := turn.start; i < turn.end; i++ {
+			drop[i] = true
+			total -= estimateMessageTokens(messages[i])
+		}
+		remaining -= turn.end - turn.start
+	}
+
+	for i, msg := range messages {
+		if drop[i] {
+			dropped = append(dropped, msg)
+		} else {
+			kept = append(kept, msg)
+		}
+	}
+	return kept, dropped
+}
+
+// insertSummary adds the summary as a system message after the leading
+// system messages
+func insertSummary(messages []openai.ChatCompletionMessage, summary string) []openai.ChatCompletionMessage {
+	at := 0
+	for at < len(messages) && messages[at].Role == openai.ChatMessageRoleSystem {
+		at++
+	}
+	result := make([]openai.ChatCompletionMessage, 0, len(messages)+1)
+	result = append(result, messages[:at]...)
+	result = append(result, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleSystem,
+		Content: "Summary of the earlier conversation: " + summary,
+	})
+	return append(result, messages[at:]...)
+}
+
+// summarize asks the model for a short summary of the given turns
+func (h *ChatHandler) summarize(ctx context.Context, model string, messages []openai.ChatCompletionMessage) (string, error) {
+	stream, err := h.client.CreateChatCompletionStream(ctx, openai.ChatCompletionRequest{
+		Model: model,
+		Messages: []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: summaryInstruction},
+			{Role: openai.ChatMessageRoleUser, Content: transcript(messages)},
+		},
+		MaxTokens: summaryMaxTokens,
+		Stream:    true,
+	})
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+
+	var summary strings.Builder
+	for {
+		response, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if len(response.Choices) > 0 {
+			summary.WriteString(response.Choices[0].Delta.Content)
+		}
+	}
+	if strings.TrimSpace(summary.String()) == "" {
+		return "", fmt.Errorf("empty summary")
+	}
+	return strings.TrimSpace(summary.String()), nil
+}
+
+// transcript renders turns as plain text for the summary request
+func transcript(messages []openai.ChatCompletionMessage) string {
+	var b strings.Builder
+	for _, msg := range messages {
+		content := msg.Content
+		for _, part := range msg.MultiContent {
+			if part.Type == openai.ChatMessagePartTypeImageURL {
+				content += " [image]"
+			} else {
+				content += " " + part.Text
+			}
+		}
+		for _, call := range msg.ToolCalls {
+			content += fmt.Sprintf(" [called %s(%s)]", call.Function.Name, call.Function.Arguments)
+		}
+		fmt.Fprintf(&b, "%s: %s\n", msg.Role, strings.TrimSpace(content))
+	}
+	return b.String()
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang-ai-stream/config"
+	apierrors "golang-ai-stream/errors"
+	"golang-ai-stream/models"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+// history returns alternating user and assistant turns of the given size,
+// each estimated at size/4 + 4 tokens
+func history(turns, size int) []models.Message {
+	messages := make([]models.Message, turns)
+	for i := range messages {
+		role := openai.ChatMessageRoleUser
+		if i%2 == 1 {
+			role = openai.ChatMessageRoleAssistant
+		}
+		messages[i] = models.Message{Role: role, Content: strings.Repeat(string(rune('a'+i)), size)}
+	}
+	return messages
+}
+
+func contextConfig(window int, strategy string) *config.Config {
+	cfg := testConfig()
+	cfg.MaxPromptLength = 1000
+	cfg.MaxTotalLength = 0
+	cfg.DefaultContextWindow = window
+	cfg.ContextStrategy = strategy
+	cfg.ContextKeepLast = 2
+	return cfg
+}
+
+func TestTrimMessages(t *testing.T) {
+	system := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleSystem, Content: strings.Repeat("s", 40)}
+	turn := func(role, content string) openai.ChatCompletionMessage {
+		return openai.ChatCompletionMessage{Role: role, Content: strings.Repeat(content, 40)}
+	}
+	call := openai.ChatCompletionMessage{
+		Role:      openai.ChatMessageRoleAssistant,
+		ToolCalls: []openai.ToolCall{{ID: "call_1", Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: "get_weather", Arguments: "{}"}}},
+	}
+	result := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleTool, ToolCallID: "call_1", Content: strings.Repeat("r", 40)}
+
+	tests := []struct {
+		name        string
+		messages    []openai.ChatCompletionMessage
+		budget      int
+		keepLast    int
+		wantKept    []openai.ChatCompletionMessage
+		wantDropped int
+	}{
+		{
+			name:        "fits",
+			messages:    []openai.ChatCompletionMessage{system, turn("user", "a")},
+			budget:      100,
+			wantKept:    []openai.ChatCompletionMessage{system, turn("user", "a")},
+			wantDropped: 0,
+		},
+		{
+			name:        "drops oldest and keeps system",
+			messages:    []openai.ChatCompletionMessage{system, turn("user", "a"), turn("assistant", "b"), turn("user", "c")},
+			budget:      30,
+			wantKept:    []openai.ChatCompletionMessage{system, turn("user", "c")},
+			wantDropped: 2,
+		},
+		{
+			name:        "keeps last n",
+			messages:    []openai.ChatCompletionMessage{system, turn("user", "a"), turn("assistant", "b"), turn("user", "c")},
+			budget:      1000,
+			keepLast:    2,
+			wantKept:    []openai.ChatCompletionMessage{system, turn("assistant", "b"), turn("user", "c")},
+			wantDropped: 1,
+		},
+		{
+			name:        "drops tool results with their call",
+			messages:    []openai.ChatCompletionMessage{turn("user", "a"), call, result, turn("user", "c")},
+			budget:      30,
+			wantKept:    []openai.ChatCompletionMessage{turn("user", "c")},
+			wantDropped: 3,
+		},
+		{
+			name:        "never drops the latest turn",
+			messages:    []openai.ChatCompletionMessage{system, turn("user", "a")},
+			budget:      10,
+			wantKept:    []openai.ChatCompletionMessage{system, turn("user", "a")},
+			wantDropped: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, dropped := trimMessages(tt.messages, tt.budget, tt.keepLast)
+			require.Equal(t, tt.wantKept, kept)
+			require.Len(t, dropped, tt.wantDropped)
+		})
+	}
+}
+
+func TestHandleChat_ContextDropOldest(t *testing.T) {
+	client := &scriptedClient{streams: []*scriptedStream{replyStream("ok")}}
+	handler := NewChatHandler(client, contextConfig(50, config.ContextDropOldest), nil)
+
+	// Five turns of 14 tokens each against a window of 50
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Messages: history(5, 40)}))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, client.reqs[0].Messages, 3)
+	require.Equal(t, strings.Repeat("c", 40), client.reqs[0].Messages[0].Content)
+
+	responses := collectResponses(t, w)
+	require.Equal(t, "context", responses[1].Type)
+	require.Equal(t, &models.ContextTrim{
+		Strategy:        "drop_oldest",
+		ContextWindow:   50,
+		DroppedMessages: 2,
+		TokensBefore:    70,
+		TokensAfter:     42,
+	}, responses[1].Context)
+}
+
+func TestHandleChat_ContextKeepLast(t *testing.T) {
+	client := &scriptedClient{streams: []*scriptedStream{replyStream("ok")}}
+	handler := NewChatHandler(client, contextConfig(50, config.ContextKeepLast), nil)
+
+	streamOff := false
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Messages: history(5, 40), Stream: &streamOff}))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, client.reqs[0].Messages, 2)
+
+	var resp models.ChatResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.Equal(t, "keep_last", resp.Context.Strategy)
+	require.Equal(t, 3, resp.Context.DroppedMessages)
+}
+
+func TestHandleChat_ContextSummarize(t *testing.T) {
+	client := &scriptedClient{streams: []*scriptedStream{replyStream("The user likes tea."), replyStream("ok")}}
+	handler := NewChatHandler(client, contextConfig(600, config.ContextSummarize), nil)
+
+	// Six turns of 104 tokens leave no room next to the summary reserve
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Messages: history(6, 400), Prompt: "hi"}))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, client.reqs, 2)
+	require.Equal(t, summaryMaxTokens, client.reqs[0].MaxTokens)
+	require.Contains(t, client.reqs[0].Messages[1].Content, "user: aaaa")
+
+	messages := client.reqs[1].Messages
+	require.Len(t, messages, 2)
+	require.Equal(t, openai.ChatMessageRoleSystem, messages[0].Role)
+	require.Equal(t, "Summary of the earlier conversation: The user likes tea.", messages[0].Content)
+	require.Equal(t, "hi", messages[1].Content)
+
+	responses := collectResponses(t, w)
+	require.Equal(t, "context", responses[1].Type)
+	require.True(t, responses[1].Context.Summarized)
+	require.Equal(t, 6, responses[1].Context.DroppedMessages)
+}
+
+func TestHandleChat_ContextSummarizeFallback(t *testing.T) {
+	client := &scriptedClient{streams: []*scriptedStream{replyStream(""), replyStream("ok")}}
+	handler := NewChatHandler(client, contextConfig(600, config.ContextSummarize), nil)
+
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Messages: history(6, 400), Prompt: "hi"}))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, client.reqs[1].Messages, 1)
+	responses := collectResponses(t, w)
+	require.False(t, responses[1].Context.Summarized)
+}
+
+func TestHandleChat_ContextExceeded(t *testing.T) {
+	client := &scriptedClient{}
+	handler := NewChatHandler(client, contextConfig(50, config.ContextDropOldest), nil)
+
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Prompt: strings.Repeat("a", 400)}))
+
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	var apiErr apierrors.APIError
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&apiErr))
+	require.Equal(t, "conversation exceeds the context window of 50 tokens", apiErr.Message)
+	require.Empty(t, client.reqs)
+}
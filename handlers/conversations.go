@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	apierrors "golang-ai-stream/errors"
+	"golang-ai-stream/logger"
+	"golang-ai-stream/middleware"
+	"golang-ai-stream/models"
+	"golang-ai-stream/store"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sashabaranov/go-openai"
+)
+
+// WithConversationStore enables conversation_id on /chat and the
+// /conversations endpoints
+func (h *ChatHandler) WithConversationStore(conversations store.ConversationStore) *ChatHandler {
+	h.conversations = conversations
+	return h
+}
+
+// rootParentID as parent_id starts a new root turn, for example to edit the
+// first message of a conversation
+const rootParentID = "root"
+
+// errNothingToRegenerate rejects regenerate when the head is not a reply
+var errNothingToRegenerate = errors.New("conversation has no assistant turn to regenerate")
+
+// conversationBranch is where a request's turns and reply are stored in the
+// message tree. IDs are assigned up front so the stream can announce them.
+type conversationBranch struct {
+	conversationID string
+	turns          []models.ConversationMessage
+	replyID        string
+	// replyParentID is the last new turn, or the branch point when the
+	// request adds no turns
+	replyParentID string
+}
+
+func (h *ChatHandler) validateConversation(reqBody *models.ChatRequest) error {
+	if reqBody.ConversationID == "" {
+		if reqBody.ParentID != "" || reqBody.Regenerate {
+			return fmt.Errorf("parent_id and regenerate require a conversation_id")
+		}
+		return nil
+	}
+	if h.conversations == nil {
+		return fmt.Errorf("conversations are not enabled")
+	}
+	if !store.ValidID(reqBody.ConversationID) {
+		return fmt.Errorf("conversation_id must be 1 to 64 letters, digits, dashes or underscores")
+	}
+	if choiceCount(reqBody) > 1 {
+		return fmt.Errorf("conversation_id cannot be combined with n > 1")
+	}
+	if reqBody.Regenerate {
+		if reqBody.ParentID != "" {
+			return fmt.Errorf("regenerate cannot be combined with parent_id")
+		}
+		if len(reqBody.Messages) > 0 || strings.TrimSpace(reqBody.Prompt) != "" {
+			return fmt.Errorf("regenerate cannot be combined with prompt or messages")
+		}
+	}
+	return nil
+}
+
+// newTurns returns the turns the client sent with this request, which are
+// stored together with the reply
+func newTurns(reqBody *models.ChatRequest) []models.Message {
+	turns := append([]models.Message(nil), reqBody.Messages...)
+	if strings.TrimSpace(reqBody.Prompt) != "" {
+		turns = append(turns, models.Message{Role: openai.ChatMessageRoleUser, Content: reqBody.Prompt})
+	}
+	return turns
+}
+
+// loadBranch prepends the stored branch the request continues to its
+// messages: the head by default, the message at parent_id, nothing for the
+// root, or the turns before the head reply when regenerating. An unknown ID
+// starts a new conversation.
+func (h *ChatHandler) loadBranch(ctx context.Context, reqBody *models.ChatRequest) (*conversationBranch, error) {
+	if reqBody.ConversationID == "" {
+		return nil, nil
+	}
+	turns := newTurns(reqBody)
+	conversation, err := h.conversations.Get(ctx, reqBody.ConversationID)
+	if errors.Is(err, store.ErrNotFound) && (reqBody.ParentID == "" || reqBody.ParentID == rootParentID) && !reqBody.Regenerate {
+		conversation, err = &models.Conversation{ID: reqBody.ConversationID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	parentID := conversation.Head
+	switch {
+	case reqBody.Regenerate:
+		head := conversation.Find(conversation.Head)
+		if head == nil || head.Message.Role != openai.ChatMessageRoleAssistant {
+			return nil, errNothingToRegenerate
+		}
+		parentID = head.ParentID
+	case reqBody.ParentID == rootParentID:
+		parentID = ""
+	case reqBody.ParentID != "":
+		if conversation.Find(reqBody.ParentID) == nil {
+			return nil, store.ErrParentNotFound
+		}
+		parentID = reqBody.ParentID
+	}
+	reqBody.Messages = append(conversation.Branch(parentID), reqBody.Messages...)
+
+	branch := &conversationBranch{conversationID: conversation.ID, replyID: uuid.NewString()}
+	for _, turn := range turns {
+		id := uuid.NewString()
+		branch.turns = append(branch.turns, models.ConversationMessage{ID: id, ParentID: parentID, Message: turn})
+		parentID = id
+	}
+	branch.replyParentID = parentID
+	return branch, nil
+}
+
+// branchError maps a loadBranch failure to an API error
+func branchError(err error) *apierrors.APIError {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return apierrors.ErrNotFound("Conversation not found")
+	case errors.Is(err, store.ErrParentNotFound):
+		return apierrors.ErrNotFound("Parent message not found")
+	case errors.Is(err, errNothingToRegenerate):
+		return apierrors.ErrBadRequest(err.Error())
+	}
+	return apierrors.ErrInternalServer("Failed to load conversation")
+}
+
+// meta describes the branch for the connected event
+func (b *conversationBranch) meta() *models.BranchMeta {
+	if b == nil {
+		return nil
+	}
+	return &models.BranchMeta{ConversationID: b.conversationID, ParentID: b.replyParentID, MessageID: b.replyID}
+}
+
+// saveConversation stores the new turns and the assembled reply once a
+// generation has finished and records where they went on the done event
+func (h *ChatHandler) saveConversation(ctx context.Context, job *streamJob, reply models.Message, done *models.ChatResponse) {
+	branch := job.branch
+	if branch == nil {
+		return
+	}
+	messages := append(append([]models.ConversationMessage(nil), branch.turns...), models.ConversationMessage{
+		ID:       branch.replyID,
+		ParentID: branch.replyParentID,
+		Message:  reply,
+	})
+	if err := h.conversations.Append(ctx, branch.conversationID, messages...); err != nil {
+		logger.LogError(job.requestID, err, "Failed to save conversation")
+		return
+	}
+	done.ConversationID = branch.conversationID
+	done.MessageID = branch.replyID
+	done.ParentID = branch.replyParentID
+}
+
+// HandleRegenerate streams a new reply for the last assistant turn of a
+// conversation. The previous reply is kept as a sibling in the tree.
+func (h *ChatHandler) HandleRegenerate(w http.ResponseWriter, r *http.Request) {
+	h.handleConversationChat(w, r, func(reqBody *models.ChatRequest) {
+		reqBody.Regenerate = true
+	})
+}
+
+// HandleBranch continues a conversation from an earlier message, forking
+// a new branch when that message already has replies
+func (h *ChatHandler) HandleBranch(w http.ResponseWriter, r *http.Request) {
+	h.handleConversationChat(w, r, func(reqBody *models.ChatRequest) {
+		reqBody.ParentID = mux.Vars(r)["message_id"]
+	})
+}
+
+// handleConversationChat runs a chat request against the conversation in
+// the path. The body is optional and accepts the /chat fields.
+func (h *ChatHandler) handleConversationChat(w http.ResponseWriter, r *http.Request, configure func(*models.ChatRequest)) {
+	requestID := r.Context().Value(middleware.RequestIDKey).(string)
+	var reqBody models.ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil && !errors.Is(err, io.EOF) {
+		logger.LogError(requestID, err, "Invalid request payload")
+		apierrors.ErrBadRequest("Invalid request payload").WithRequestID(requestID).RespondWithError(w)
+		return
+	}
+	reqBody.ConversationID = mux.Vars(r)["conversation_id"]
+	configure(&reqBody)
+	h.serveChat(w, r, &reqBody)
+}
+
+// HandleListConversations returns summaries of all stored conversations
+func (h *ChatHandler) HandleListConversations(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(middleware.RequestIDKey).(string)
+	if h.conversations == nil {
+		apierrors.ErrNotFound("Conversations are not enabled").WithRequestID(requestID).RespondWithError(w)
+		return
+	}
+
+	summaries, err := h.conversations.List(r.Context())
+	if err != nil {
+		logger.LogError(requestID, err, "Failed to list conversations")
+		apierrors.ErrInternalServer("Failed to list conversations").WithRequestID(requestID).RespondWithError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]models.ConversationSummary{"conversations": summaries})
+}
+
+// HandleGetConversation returns a stored conversation with its messages
+func (h *ChatHandler) HandleGetConversation(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(middleware.RequestIDKey).(string)
+	if h.conversations == nil {
+		apierrors.ErrNotFound("Conversations are not enabled").WithRequestID(requestID).RespondWithError(w)
+		return
+	}
+
+	conversation, err := h.conversations.Get(r.Context(), mux.Vars(r)["conversation_id"])
+	if errors.Is(err, store.ErrNotFound) {
+		apierrors.ErrNotFound("Conversation not found").WithRequestID(requestID).RespondWithError(w)
+		return
+	}
+	if err != nil {
+		logger.LogError(requestID, err, "Failed to load conversation")
+		apierrors.ErrInternalServer("Failed to load conversation").WithRequestID(requestID).RespondWithError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(conversation)
+}
+
+// HandleDeleteConversation removes a stored conversation
+func (h *ChatHandler) HandleDeleteConversation(w http.ResponseWriter, r *http.Request) {
+	requestID := r.Context().Value(middleware.RequestIDKey).(string)
+	if h.conversations == nil {
+		apierrors.ErrNotFound("Conversations are not enabled").WithRequestID(requestID).RespondWithError(w)
+		return
+	}
+
+	err := h.conversations.Delete(r.Context(), mux.Vars(r)["conversation_id"])
+	if errors.Is(err, store.ErrNotFound) {
+		apierrors.ErrNotFound("Conversation not found").WithRequestID(requestID).RespondWithError(w)
+		return
+	}
+	if err != nil {
+		logger.LogError(requestID, err, "Failed to delete conversation")
+		apierrors.ErrInternalServer("Failed to delete conversation").WithRequestID(requestID).RespondWithError(w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
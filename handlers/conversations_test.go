@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	apierrors "golang-ai-stream/errors"
+	"golang-ai-stream/middleware"
+	"golang-ai-stream/models"
+	"golang-ai-stream/store"
+
+	"github.com/gorilla/mux"
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func conversationRequest(t *testing.T, method, id string) *http.Request {
+	req := httptest.NewRequest(method, "/conversations/"+id, nil)
+	req = mux.SetURLVars(req, map[string]string{"conversation_id": id})
+	return req.WithContext(context.WithValue(req.Context(), middleware.RequestIDKey, "test-id"))
+}
+
+func TestHandleChat_Conversation(t *testing.T) {
+	conversations := store.NewMemoryStore()
+	client := &scriptedClient{streams: []*scriptedStream{replyStream("Hello!"), replyStream("Your name is Ada.")}}
+	handler := NewChatHandler(client, testConfig(), nil).WithConversationStore(conversations)
+
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Prompt: "I am Ada", ConversationID: "c1"}))
+	require.Equal(t, http.StatusOK, w.Code)
+	responses := collectResponses(t, w)
+	done := responses[len(responses)-1]
+	require.Equal(t, "done", done.Type)
+	require.Equal(t, "c1", done.ConversationID)
+	require.Equal(t, &models.BranchMeta{ConversationID: "c1", ParentID: done.ParentID, MessageID: done.MessageID}, responses[0].Meta.Branch)
+
+	streamOff := false
+	w = httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Prompt: "Who am I?", ConversationID: "c1", Stream: &streamOff}))
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp models.ChatResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.Equal(t, "c1", resp.ConversationID)
+
+	messages := client.reqs[1].Messages
+	require.Len(t, messages, 3)
+	require.Equal(t, "I am Ada", messages[0].Content)
+	require.Equal(t, openai.ChatMessageRoleAssistant, messages[1].Role)
+	require.Equal(t, "Hello!", messages[1].Content)
+	require.Equal(t, "Who am I?", messages[2].Content)
+
+	conversation, err := conversations.Get(context.Background(), "c1")
+	require.NoError(t, err)
+	require.Len(t, conversation.Messages, 4)
+	require.Equal(t, "Your name is Ada.", conversation.Messages[3].Message.Content)
+	require.Equal(t, resp.MessageID, conversation.Head)
+	require.Equal(t, conversation.Messages[2].ID, resp.ParentID)
+}
+
+func TestHandleChat_ConversationErrors(t *testing.T) {
+	two := 2
+	tests := []struct {
+		name        string
+		store       store.ConversationStore
+		reqBody     models.ChatRequest
+		wantMessage string
+	}{
+		{
+			name:        "not enabled",
+			reqBody:     models.ChatRequest{Prompt: "hi", ConversationID: "c1"},
+			wantMessage: "conversations are not enabled",
+		},
+		{
+			name:        "invalid id",
+			store:       store.NewMemoryStore(),
+			reqBody:     models.ChatRequest{Prompt: "hi", ConversationID: "../etc"},
+			wantMessage: "conversation_id must be 1 to 64 letters, digits, dashes or underscores",
+		},
+		{
+			name:        "multiple choices",
+			store:       store.NewMemoryStore(),
+			reqBody:     models.ChatRequest{Prompt: "hi", ConversationID: "c1", N: &two},
+			wantMessage: "conversation_id cannot be combined with n > 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.MaxChoices = 4
+			handler := NewChatHandler(&scriptedClient{}, cfg, nil)
+			if tt.store != nil {
+				handler.WithConversationStore(tt.store)
+			}
+
+			w := httptest.NewRecorder()
+			handler.HandleChat(w, newTestRequest(t, "/chat", tt.reqBody))
+
+			require.Equal(t, http.StatusBadRequest, w.Code)
+			var apiErr apierrors.APIError
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&apiErr))
+			require.Equal(t, tt.wantMessage, apiErr.Message)
+		})
+	}
+}
+
+func TestConversationEndpoints(t *testing.T) {
+	conversations := store.NewMemoryStore()
+	require.NoError(t, conversations.Append(context.Background(), "c1",
+		models.ConversationMessage{ID: "m1", Message: models.Message{Role: openai.ChatMessageRoleUser, Content: "hi"}},
+		models.ConversationMessage{ID: "m2", ParentID: "m1", Message: models.Message{Role: openai.ChatMessageRoleAssistant, Content: "hello"}},
+	))
+	handler := NewChatHandler(&scriptedClient{}, testConfig(), nil).WithConversationStore(conversations)
+
+	w := httptest.NewRecorder()
+	handler.HandleListConversations(w, conversationRequest(t, http.MethodGet, ""))
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Conversations []models.ConversationSummary `json:"conversations"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&list))
+	require.Len(t, list.Conversations, 1)
+	require.Equal(t, "c1", list.Conversations[0].ID)
+	require.Equal(t, 2, list.Conversations[0].MessageCount)
+
+	w = httptest.NewRecorder()
+	handler.HandleGetConversation(w, conversationRequest(t, http.MethodGet, "c1"))
+	require.Equal(t, http.StatusOK, w.Code)
+	var conversation models.Conversation
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&conversation))
+	require.Len(t, conversation.Messages, 2)
+	require.Equal(t, "hello", conversation.Messages[1].Message.Content)
+	require.Equal(t, "m2", conversation.Head)
+
+	w = httptest.NewRecorder()
+	handler.HandleDeleteConversation(w, conversationRequest(t, http.MethodDelete, "c1"))
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.HandleGetConversation(w, conversationRequest(t, http.MethodGet, "c1"))
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.HandleDeleteConversation(w, conversationRequest(t, http.MethodDelete, "c1"))
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	NewChatHandler(&scriptedClient{}, testConfig(), nil).HandleListConversations(w, conversationRequest(t, http.MethodGet, ""))
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestConversationBranching(t *testing.T) {
+	conversations := store.NewMemoryStore()
+	client := &scriptedClient{streams: []*scriptedStream{replyStream("Hello!"), replyStream("Hi Ada!"), replyStream("Tea.")}}
+	handler := NewChatHandler(client, testConfig(), nil).WithConversationStore(conversations)
+
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Prompt: "I am Ada", ConversationID: "c1"}))
+	responses := collectResponses(t, w)
+	first := responses[len(responses)-1]
+
+	// Regenerating replaces the reply with a sibling below the same turn
+	w = httptest.NewRecorder()
+	req := mux.SetURLVars(newTestRequest(t, "/conversations/c1/regenerate", struct{}{}), map[string]string{"conversation_id": "c1"})
+	handler.HandleRegenerate(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	responses = collectResponses(t, w)
+	regenerated := responses[len(responses)-1]
+	require.Equal(t, first.ParentID, regenerated.ParentID)
+	require.NotEqual(t, first.MessageID, regenerated.MessageID)
+	require.Len(t, client.reqs[1].Messages, 1)
+	require.Equal(t, "I am Ada", client.reqs[1].Messages[0].Content)
+
+	// Branching from the first reply continues the original thread
+	w = httptest.NewRecorder()
+	req = mux.SetURLVars(newTestRequest(t, "/conversations/c1/messages/x/branch", models.ChatRequest{Prompt: "Favourite drink?"}),
+		map[string]string{"conversation_id": "c1", "message_id": first.MessageID})
+	handler.HandleBranch(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	messages := client.reqs[2].Messages
+	require.Len(t, messages, 3)
+	require.Equal(t, "Hello!", messages[1].Content)
+	require.Equal(t, "Favourite drink?", messages[2].Content)
+
+	conversation, err := conversations.Get(context.Background(), "c1")
+	require.NoError(t, err)
+	require.Len(t, conversation.Messages, 5)
+	var branch []string
+	for _, msg := range conversation.Branch(conversation.Head) {
+		branch = append(branch, msg.Content)
+	}
+	require.Equal(t, []string{"I am Ada", "Hello!", "Favourite drink?", "Tea."}, branch)
+}
+
+func TestConversationBranchingErrors(t *testing.T) {
+	conversations := store.NewMemoryStore()
+	require.NoError(t, conversations.Append(context.Background(), "pending",
+		models.ConversationMessage{ID: "m1", Message: models.Message{Role: openai.ChatMessageRoleUser, Content: "hi"}},
+	))
+
+	tests := []struct {
+		name        string
+		reqBody     models.ChatRequest
+		wantStatus  int
+		wantMessage string
+	}{
+		{
+			name:        "parent without conversation",
+			reqBody:     models.ChatRequest{Prompt: "hi", ParentID: "m1"},
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "parent_id and regenerate require a conversation_id",
+		},
+		{
+			name:        "regenerate with prompt",
+			reqBody:     models.ChatRequest{Prompt: "hi", ConversationID: "pending", Regenerate: true},
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "regenerate cannot be combined with prompt or messages",
+		},
+		{
+			name:        "regenerate with parent",
+			reqBody:     models.ChatRequest{ConversationID: "pending", ParentID: "m1", Regenerate: true},
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "regenerate cannot be combined with parent_id",
+		},
+		{
+			name:        "nothing to regenerate",
+			reqBody:     models.ChatRequest{ConversationID: "pending", Regenerate: true},
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "conversation has no assistant turn to regenerate",
+		},
+		{
+			name:        "unknown conversation",
+			reqBody:     models.ChatRequest{ConversationID: "missing", Regenerate: true},
+			wantStatus:  http.StatusNotFound,
+			wantMessage: "Conversation not found",
+		},
+		{
+			name:        "unknown parent",
+			reqBody:     models.ChatRequest{Prompt: "hi", ConversationID: "pending", ParentID: "m9"},
+			wantStatus:  http.StatusNotFound,
+			wantMessage: "Parent message not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &scriptedClient{}
+			handler := NewChatHandler(client, testConfig(), nil).WithConversationStore(conversations)
+
+			w := httptest.NewRecorder()
+			handler.HandleChat(w, newTestRequest(t, "/chat", tt.reqBody))
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			var apiErr apierrors.APIError
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&apiErr))
+			require.Equal(t, tt.wantMessage, apiErr.Message)
+			require.Empty(t, client.reqs)
+		})
+	}
+}
+
+func TestConversationBranching_EditFirstTurn(t *testing.T) {
+	conversations := store.NewMemoryStore()
+	client := &scriptedClient{streams: []*scriptedStream{replyStream("Hello Ada!"), replyStream("Hello Bob!")}}
+	handler := NewChatHandler(client, testConfig(), nil).WithConversationStore(conversations)
+
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Prompt: "I am Ada", ConversationID: "c1"}))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Editing the first turn forks at the root instead of the head
+	w = httptest.NewRecorder()
+	req := mux.SetURLVars(newTestRequest(t, "/conversations/c1/messages/root/branch", models.ChatRequest{Prompt: "I am Bob"}),
+		map[string]string{"conversation_id": "c1", "message_id": rootParentID})
+	handler.HandleBranch(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, client.reqs[1].Messages, 1)
+	require.Equal(t, "I am Bob", client.reqs[1].Messages[0].Content)
+
+	conversation, err := conversations.Get(context.Background(), "c1")
+	require.NoError(t, err)
+	var roots []string
+	for _, msg := range conversation.Messages {
+		if msg.ParentID == "" {
+			roots = append(roots, msg.Message.Content)
+		}
+	}
+	require.Equal(t, []string{"I am Ada", "I am Bob"}, roots)
+	var branch []string
+	for _, msg := range conversation.Branch(conversation.Head) {
+		branch = append(branch, msg.Content)
+	}
+	require.Equal(t, []string{"I am Bob", "Hello Bob!"}, branch)
+}
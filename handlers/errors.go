@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	apierrors "golang-ai-stream/errors"
+	"golang-ai-stream/models"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// errToolLoopExceeded stops a generation whose model keeps calling server
+// tools past MaxToolIterations
+var errToolLoopExceeded = errors.New("tool call iterations exceeded")
+
+// tooLargeError marks validation failures caused by size limits
+type tooLargeError struct {
+	error
+}
+
+// validationError maps a validateRequest failure to an API error
+func validationError(err error) *apierrors.APIError {
+	var tooLarge tooLargeError
+	if errors.As(err, &tooLarge) {
+		return apierrors.ErrPayloadTooLarge(err.Error())
+	}
+	return apierrors.ErrBadRequest(err.Error())
+}
+
+// upstreamStatus extracts the HTTP status code from a go-openai error, if any
+func upstreamStatus(err error) int {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HTTPStatusCode
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr.HTTPStatusCode
+	}
+	return 0
+}
+
+// classifyError maps an upstream or transport failure onto the shared error
+// type vocabulary so clients don't have to string-match messages
+func classifyError(err error) string {
+	if errors.Is(err, context.Canceled) {
+		return apierrors.TypeClientDisconnected
+	}
+	if errors.Is(err, errToolLoopExceeded) {
+		return apierrors.TypeToolLoopExceeded
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return apierrors.TypeUpstreamTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return apierrors.TypeUpstreamTimeout
+	}
+
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		code, _ := apiErr.Code.(string)
+		switch {
+		case code == "context_length_exceeded" || strings.Contains(strings.ToLower(apiErr.Message), "context length"):
+			return apierrors.TypeContextLengthExceeded
+		case code == "content_filter" || apiErr.Type == "content_filter" ||
+			(apiErr.InnerError != nil && apiErr.InnerError.Code == "ResponsibleAIPolicyViolation"):
+			return apierrors.TypeContentFilter
+		}
+	}
+
+	switch upstreamStatus(err) {
+	case http.StatusTooManyRequests:
+		return apierrors.TypeRateLimited
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return apierrors.TypeUpstreamTimeout
+	}
+	return apierrors.TypeUpstreamError
+}
+
+// upstreamError maps a failure to reach the upstream provider to an API error.
+// Overload and rate limiting upstream surface as 503, everything else as 502.
+func upstreamError(err error, msg string) *apierrors.APIError {
+	return classifiedUpstreamError(upstreamStatus(err), classifyError(err), msg)
+}
+
+// classifiedUpstreamError builds the API error for an upstream failure that
+// was already classified, such as one reported by an SSE error event
+func classifiedUpstreamError(status int, errorType, msg string) *apierrors.APIError {
+	var apiErr *apierrors.APIError
+	switch status {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		apiErr = apierrors.ErrServiceUnavailable(msg)
+	default:
+		apiErr = apierrors.ErrBadGateway(msg)
+	}
+	if errorType != apierrors.TypeUpstreamError {
+		apiErr.WithType(errorType)
+	}
+	return apiErr
+}
+
+// streamErrorMessages are the client-facing messages for mid-stream failures
+var streamErrorMessages = map[string]string{
+	apierrors.TypeClientDisconnected:    "Client disconnected",
+	apierrors.TypeUpstreamTimeout:       "Upstream provider timed out",
+	apierrors.TypeRateLimited:           "Upstream provider rate limit exceeded",
+	apierrors.TypeContentFilter:         "Response blocked by content filter",
+	apierrors.TypeContextLengthExceeded: "Conversation exceeds the model context length",
+	apierrors.TypeToolLoopExceeded:      "Too many tool call iterations",
+	apierrors.TypeUpstreamError:         "Failed to receive chat completion",
+}
+
+// streamErrorChunk builds a typed SSE error event for a mid-stream failure
+func streamErrorChunk(requestID string, err error) models.ChatResponse {
+	errorType := classifyError(err)
+	return models.ChatResponse{
+		Content:   streamErrorMessages[errorType],
+		RequestID: requestID,
+		Type:      "error",
+		Error: &models.ErrorDetail{
+			ErrorType: errorType,
+			Code:      upstreamStatus(err),
+		},
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	apierrors "golang-ai-stream/errors"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpstreamError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{name: "generic error", err: fmt.Errorf("boom"), wantStatus: http.StatusBadGateway},
+		{name: "upstream 500", err: &openai.APIError{HTTPStatusCode: 500}, wantStatus: http.StatusBadGateway},
+		{name: "upstream 429", err: &openai.APIError{HTTPStatusCode: 429}, wantStatus: http.StatusServiceUnavailable},
+		{name: "upstream 503", err: &openai.RequestError{HTTPStatusCode: 503}, wantStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.wantStatus, upstreamError(tt.err, "failed").Code)
+		})
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "generic", err: fmt.Errorf("boom"), want: apierrors.TypeUpstreamError},
+		{name: "canceled", err: fmt.Errorf("recv: %w", context.Canceled), want: apierrors.TypeClientDisconnected},
+		{name: "deadline", err: context.DeadlineExceeded, want: apierrors.TypeUpstreamTimeout},
+		{name: "gateway timeout", err: &openai.RequestError{HTTPStatusCode: http.StatusGatewayTimeout}, want: apierrors.TypeUpstreamTimeout},
+		{name: "rate limited", err: &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests}, want: apierrors.TypeRateLimited},
+		{name: "context length code", err: &openai.APIError{Code: "context_length_exceeded", HTTPStatusCode: 400}, want: apierrors.TypeContextLengthExceeded},
+		{name: "context length message", err: &openai.APIError{Message: "This model's maximum context length is 8192 tokens"}, want: apierrors.TypeContextLengthExceeded},
+		{name: "content filter", err: &openai.APIError{InnerError: &openai.InnerError{Code: "ResponsibleAIPolicyViolation"}}, want: apierrors.TypeContentFilter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, classifyError(tt.err))
+		})
+	}
+}
+
+func TestStreamErrorChunk(t *testing.T) {
+	chunk := streamErrorChunk("test-id", &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests})
+
+	require.Equal(t, "error", chunk.Type)
+	require.Equal(t, "test-id", chunk.RequestID)
+	require.Equal(t, apierrors.TypeRateLimited, chunk.Error.ErrorType)
+	require.Equal(t, http.StatusTooManyRequests, chunk.Error.Code)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang-ai-stream/models"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// validateParts checks the content parts of message i and returns the
+// length of their text
+func (h *ChatHandler) validateParts(i int, parts []models.ContentPart) (int, error) {
+	size := 0
+	for j, part := range parts {
+		switch openai.ChatMessagePartType(part.Type) {
+		case openai.ChatMessagePartTypeText:
+			size += len(part.Text)
+		case openai.ChatMessagePartTypeImageURL:
+			if part.ImageURL == nil || part.ImageURL.URL == "" {
+				return 0, fmt.Errorf("message %d part %d image_url cannot be empty", i, j)
+			}
+			if err := h.validateImageURL(part.ImageURL.URL); err != nil {
+				return 0, fmt.Errorf("message %d part %d: %w", i, j, err)
+			}
+		default:
+			return 0, fmt.Errorf("message %d part %d has unsupported type %q", i, j, part.Type)
+		}
+	}
+	return size, nil
+}
+
+// validateImageURL enforces the configured MIME types and size limit. Data
+// URIs are checked exactly; remote URLs only by their file extension, since
+// the upstream provider fetches them.
+func (h *ChatHandler) validateImageURL(imageURL string) error {
+	if strings.HasPrefix(imageURL, "data:") {
+		mimeType, data, ok := parseDataURI(imageURL)
+		if !ok {
+			return fmt.Errorf("image must be a base64 data URI")
+		}
+		if !h.isImageTypeAllowed(mimeType) {
+			return fmt.Errorf("image type %q is not allowed", mimeType)
+		}
+		// Reject oversized payloads before paying for decoding them
+		if h.config.MaxImageBytes > 0 && base64.StdEncoding.DecodedLen(len(data)) > h.config.MaxImageBytes+2 {
+			return tooLargeError{fmt.Errorf("image exceeds maximum size of %d bytes", h.config.MaxImageBytes)}
+		}
+		decoded, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return fmt.Errorf("image data is not valid base64")
+		}
+		if h.config.MaxImageBytes > 0 && len(decoded) > h.config.MaxImageBytes {
+			return tooLargeError{fmt.Errorf("image exceeds maximum size of %d bytes", h.config.MaxImageBytes)}
+		}
+		return nil
+	}
+
+	parsed, err := url.Parse(imageURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("image URL must be http(s) or a data URI")
+	}
+	if mimeType := mime.TypeByExtension(path.Ext(parsed.Path)); mimeType != "" && !h.isImageTypeAllowed(mimeType) {
+		return fmt.Errorf("image type %q is not allowed", mimeType)
+	}
+	return nil
+}
+
+func (h *ChatHandler) isImageTypeAllowed(mimeType string) bool {
+	for _, allowed := range h.config.AllowedImageTypes {
+		if strings.EqualFold(allowed, mimeType) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDataURI splits a data:<mime>;base64,<data> URI
+func parseDataURI(uri string) (string, string, bool) {
+	header, data, ok := strings.Cut(strings.TrimPrefix(uri, "data:"), ",")
+	if !ok {
+		return "", "", false
+	}
+	mimeType, encoding, ok := strings.Cut(header, ";")
+	if !ok || encoding != "base64" {
+		return "", "", false
+	}
+	return mimeType, data, true
+}
+
+func toOpenAIParts(parts []models.ContentPart) []openai.ChatMessagePart {
+	result := make([]openai.ChatMessagePart, 0, len(parts))
+	for _, part := range parts {
+		converted := openai.ChatMessagePart{Type: openai.ChatMessagePartType(part.Type), Text: part.Text}
+		if part.ImageURL != nil {
+			converted.ImageURL = &openai.ChatMessageImageURL{
+				URL:    part.ImageURL.URL,
+				Detail: openai.ImageURLDetail(part.ImageURL.Detail),
+			}
+		}
+		result = append(result, converted)
+	}
+	return result
+}
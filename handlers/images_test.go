@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	apierrors "golang-ai-stream/errors"
+	"golang-ai-stream/models"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func imageConfigHandler(client OpenAIClient) *ChatHandler {
+	cfg := testConfig()
+	cfg.MaxImageBytes = 16
+	cfg.AllowedImageTypes = []string{"image/png", "image/jpeg"}
+	return NewChatHandler(client, cfg, nil)
+}
+
+func dataURI(mimeType string, size int) string {
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(make([]byte, size))
+}
+
+func TestMessage_ContentParts(t *testing.T) {
+	var reqBody models.ChatRequest
+	require.NoError(t, json.Unmarshal([]byte(`{"messages":[
+		{"role":"user","content":"plain"},
+		{"role":"user","content":[{"type":"text","text":"what is this?"},{"type":"image_url","image_url":{"url":"https://example.com/a.png","detail":"low"}}]},
+		{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"f","arguments":"{}"}}]}
+	]}`), &reqBody))
+
+	require.Equal(t, "plain", reqBody.Messages[0].Content)
+	require.Len(t, reqBody.Messages[1].Parts, 2)
+	require.Equal(t, "https://example.com/a.png", reqBody.Messages[1].Parts[1].ImageURL.URL)
+	require.Equal(t, "call_1", reqBody.Messages[2].ToolCalls[0].ID)
+
+	encoded, err := json.Marshal(reqBody.Messages[1])
+	require.NoError(t, err)
+	require.JSONEq(t, `{"role":"user","content":[{"type":"text","text":"what is this?"},{"type":"image_url","image_url":{"url":"https://example.com/a.png","detail":"low"}}]}`, string(encoded))
+}
+
+func TestValidateImageURL(t *testing.T) {
+	handler := imageConfigHandler(&scriptedClient{})
+
+	tests := []struct {
+		name    string
+		url     string
+		wantErr string
+	}{
+		{name: "data uri", url: dataURI("image/png", 16)},
+		{name: "remote url", url: "https://example.com/screenshot.jpg"},
+		{name: "remote url without extension", url: "https://example.com/image"},
+		{name: "too large", url: dataURI("image/png", 17), wantErr: "image exceeds maximum size of 16 bytes"},
+		{name: "mime not allowed", url: dataURI("image/gif", 4), wantErr: `image type "image/gif" is not allowed`},
+		{name: "remote mime not allowed", url: "https://example.com/anim.gif", wantErr: `image type "image/gif" is not allowed`},
+		{name: "not base64", url: "data:image/png,abc", wantErr: "image must be a base64 data URI"},
+		{name: "invalid base64", url: "data:image/png;base64,***", wantErr: "image data is not valid base64"},
+		{name: "bad scheme", url: "file:///etc/passwd", wantErr: "image URL must be http(s) or a data URI"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := handler.validateImageURL(tt.url)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestHandleChat_ImageParts(t *testing.T) {
+	client := &scriptedClient{}
+	handler := imageConfigHandler(client)
+
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Messages: []models.Message{{
+		Role: "user",
+		Parts: []models.ContentPart{
+			{Type: "text", Text: "what is this?"},
+			{Type: "image_url", ImageURL: &models.ImageURL{URL: dataURI("image/png", 8)}},
+		},
+	}}}))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	message := client.reqs[0].Messages[0]
+	require.Empty(t, message.Content)
+	require.Len(t, message.MultiContent, 2)
+	require.Equal(t, openai.ChatMessagePartTypeImageURL, message.MultiContent[1].Type)
+	require.True(t, strings.HasPrefix(message.MultiContent[1].ImageURL.URL, "data:image/png;base64,"))
+}
+
+func TestHandleChat_ImageValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		parts       []models.ContentPart
+		wantStatus  int
+		wantMessage string
+	}{
+		{
+			name:        "image too large",
+			parts:       []models.ContentPart{{Type: "image_url", ImageURL: &models.ImageURL{URL: dataURI("image/png", 64)}}},
+			wantStatus:  http.StatusRequestEntityTooLarge,
+			wantMessage: "message 0 part 0: image exceeds maximum size of 16 bytes",
+		},
+		{
+			name:        "unsupported part",
+			parts:       []models.ContentPart{{Type: "input_audio"}},
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: `message 0 part 0 has unsupported type "input_audio"`,
+		},
+		{
+			name:        "text parts count towards length",
+			parts:       []models.ContentPart{{Type: "text", Text: strings.Repeat("a", 101)}},
+			wantStatus:  http.StatusRequestEntityTooLarge,
+			wantMessage: "message 0 exceeds maximum length of 100 characters",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := imageConfigHandler(&scriptedClient{})
+
+			w := httptest.NewRecorder()
+			handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Messages: []models.Message{{Role: "user", Parts: tt.parts}}}))
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			var apiErr apierrors.APIError
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&apiErr))
+			require.Equal(t, tt.wantMessage, apiErr.Message)
+		})
+	}
+}
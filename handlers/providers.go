@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"golang-ai-stream/config"
+	"golang-ai-stream/logger"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ProviderRegistry is an OpenAIClient that sends each request to the backend
+// serving its model
+type ProviderRegistry struct {
+	mu        sync.RWMutex
+	clients   map[string]OpenAIClient
+	routes    map[string]string
+	fallback  string
+	fallbacks map[string][]config.FallbackTarget
+}
+
+func NewProviderRegistry() *ProviderRegistry {
+	return &ProviderRegistry{
+		clients:   make(map[string]OpenAIClient),
+		routes:    make(map[string]string),
+		fallbacks: make(map[string][]config.FallbackTarget),
+	}
+}
+
+// Register adds a backend for the given models. A backend registered
+// without models serves every model no other backend claims.
+func (p *ProviderRegistry) Register(name string, client OpenAIClient, models []string) error {
+	if name == "" {
+		return fmt.Errorf("provider name cannot be empty")
+	}
+	if client == nil {
+		return fmt.Errorf("provider %q has no client", name)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, exists := p.clients[name]; exists {
+		return fmt.Errorf("provider %q is already registered", name)
+	}
+	if len(models) == 0 && p.fallback != "" {
+		return fmt.Errorf("provider %q already serves unlisted models", p.fallback)
+	}
+	for _, model := range models {
+		if owner, ok := p.routes[model]; ok {
+			return fmt.Errorf("model %q is already served by provider %q", model, owner)
+		}
+	}
+
+	p.clients[name] = client
+	for _, model := range models {
+		p.routes[model] = name
+	}
+	if len(models) == 0 {
+		p.fallback = name
+	}
+	return nil
+}
+
+// Route returns the name and client of the backend serving model
+func (p *ProviderRegistry) Route(model string) (string, OpenAIClient, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	name, ok := p.routes[model]
+	if !ok {
+		name = p.fallback
+	}
+	if name == "" {
+		return "", nil, fmt.Errorf("no provider serves model %q", model)
+	}
+	return name, p.clients[name], nil
+}
+
+// SetFallbacks sets the targets tried in order when the provider serving
+// model fails to start a stream
+func (p *ProviderRegistry) SetFallbacks(model string, targets []config.FallbackTarget) error {
+	for i, target := range targets {
+		if _, _, err := p.resolve(model, target); err != nil {
+			return fmt.Errorf("fallback %d of %q: %v", i, model, err)
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fallbacks[model] = targets
+	return nil
+}
+
+// resolve returns the provider and model a fallback target points at
+func (p *ProviderRegistry) resolve(model string, target config.FallbackTarget) (string, string, error) {
+	if target.Model != "" {
+		model = target.Model
+	}
+	if target.Provider == "" {
+		name, _, err := p.Route(model)
+		return name, model, err
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if _, ok := p.clients[target.Provider]; !ok {
+		return "", "", fmt.Errorf("unknown provider %q", target.Provider)
+	}
+	return target.Provider, model, nil
+}
+
+// CreateChatCompletionStream starts the stream on the provider serving the
+// model. When that fails with a rate limit, timeout, 5xx or network error
+// the model's fallbacks are tried in order; nothing has been streamed yet,
+// so the client never sees the failed attempts.
+func (p *ProviderRegistry) CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (ChatCompletionStreamer, error) {
+	name, _, err := p.Route(req.Model)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.RLock()
+	targets := append([]config.FallbackTarget{{Provider: name}}, p.fallbacks[req.Model]...)
+	p.mu.RUnlock()
+
+	for i, target := range targets {
+		provider, model, err := p.resolve(req.Model, target)
+		if err != nil {
+			return nil, err
+		}
+		p.mu.RLock()
+		client := p.clients[provider]
+		p.mu.RUnlock()
+
+		attempt := req
+		attempt.Model = model
+		stream, err := client.CreateChatCompletionStream(ctx, attempt)
+		if err == nil {
+			return &servedStream{ChatCompletionStreamer: stream, provider: provider, model: model}, nil
+		}
+		if i == len(targets)-1 || !shouldFailover(ctx, err) {
+			return nil, err
+		}
+		logger.LogError("", err, fmt.Sprintf("Provider %s failed for model %s, failing over", provider, model))
+	}
+	return nil, fmt.Errorf("no provider serves model %q", req.Model)
+}
+
+// shouldFailover reports whether another provider may succeed where err
+// failed: rate limits, timeouts, server and network errors, but not
+// rejected requests or a cancelled client
+func shouldFailover(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	switch status := upstreamStatus(err); {
+	case status == 0:
+		return true
+	case status == http.StatusRequestTimeout || status == http.StatusTooManyRequests:
+		return true
+	default:
+		return status >= http.StatusInternalServerError
+	}
+}
+
+// ServedStream is implemented by streams that know which provider and
+// model served them, which may differ from the request after a failover
+type ServedStream interface {
+	ChatCompletionStreamer
+	ServedBy() (provider, model string)
+}
+
+// servedStream wraps the stream of the provider that accepted the request
+type servedStream struct {
+	ChatCompletionStreamer
+	provider string
+	model    string
+}
+
+func (s *servedStream) ServedBy() (string, string) {
+	return s.provider, s.model
+}
+
+// Reasoning passes through the wrapped stream's reasoning deltas
+func (s *servedStream) Reasoning(choice int) string {
+	return reasoningDelta(s.ChatCompletionStreamer, choice)
+}
+
+// servedBy returns the provider and model behind stream. Streams from a
+// client without providers report the requested model only.
+func servedBy(stream ChatCompletionStreamer, model string) (string, string) {
+	if ss, ok := stream.(ServedStream); ok {
+		return ss.ServedBy()
+	}
+	return "", model
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang-ai-stream/config"
+	"golang-ai-stream/models"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderRegistry_Register(t *testing.T) {
+	registry := NewProviderRegistry()
+	require.NoError(t, registry.Register("openai", &scriptedClient{}, []string{"gpt-4o"}))
+	require.NoError(t, registry.Register("openrouter", &scriptedClient{}, nil))
+
+	require.EqualError(t, registry.Register("", &scriptedClient{}, nil), "provider name cannot be empty")
+	require.EqualError(t, registry.Register("azure", nil, nil), `provider "azure" has no client`)
+	require.EqualError(t, registry.Register("openai", &scriptedClient{}, []string{"o1"}), `provider "openai" is already registered`)
+	require.EqualError(t, registry.Register("azure", &scriptedClient{}, []string{"gpt-4o"}), `model "gpt-4o" is already served by provider "openai"`)
+	require.EqualError(t, registry.Register("local", &scriptedClient{}, nil), `provider "openrouter" already serves unlisted models`)
+}
+
+func TestProviderRegistry_Route(t *testing.T) {
+	openaiClient := &scriptedClient{}
+	localClient := &scriptedClient{}
+	registry := NewProviderRegistry()
+	require.NoError(t, registry.Register("openai", openaiClient, []string{"gpt-4o"}))
+	require.NoError(t, registry.Register("local", localClient, []string{"llama3"}))
+
+	name, client, err := registry.Route("llama3")
+	require.NoError(t, err)
+	require.Equal(t, "local", name)
+	require.Same(t, localClient, client)
+
+	_, _, err = registry.Route("anthropic/claude-3.5-sonnet")
+	require.EqualError(t, err, `no provider serves model "anthropic/claude-3.5-sonnet"`)
+
+	fallbackClient := &scriptedClient{}
+	require.NoError(t, registry.Register("openrouter", fallbackClient, nil))
+	name, client, err = registry.Route("anthropic/claude-3.5-sonnet")
+	require.NoError(t, err)
+	require.Equal(t, "openrouter", name)
+	require.Same(t, fallbackClient, client)
+}
+
+func TestHandleChat_RoutesToProvider(t *testing.T) {
+	openaiClient := &scriptedClient{streams: []*scriptedStream{replyStream("from openai")}}
+	defaultClient := &scriptedClient{streams: []*scriptedStream{replyStream("from openrouter")}}
+	registry := NewProviderRegistry()
+	require.NoError(t, registry.Register("openai", openaiClient, []string{"gpt-4o"}))
+	require.NoError(t, registry.Register("openrouter", defaultClient, nil))
+
+	cfg := testConfig()
+	cfg.AllowedModels = []string{"gpt-4o"}
+	handler := NewChatHandler(registry, cfg, nil)
+
+	for _, model := range []string{"gpt-4o", ""} {
+		w := httptest.NewRecorder()
+		handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Prompt: "hi", Model: model}))
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	require.Len(t, openaiClient.reqs, 1)
+	require.Equal(t, "gpt-4o", openaiClient.reqs[0].Model)
+	require.Len(t, defaultClient.reqs, 1)
+	require.Equal(t, "test/model", defaultClient.reqs[0].Model)
+}
+
+func TestProviderRegistry_Failover(t *testing.T) {
+	unavailable := &openai.APIError{HTTPStatusCode: http.StatusServiceUnavailable, Message: "overloaded"}
+	rejected := &openai.APIError{HTTPStatusCode: http.StatusBadRequest, Message: "bad request"}
+
+	tests := []struct {
+		name         string
+		primaryErr   error
+		secondaryErr error
+		ctx          func() context.Context
+		wantErr      error
+		wantProvider string
+		wantModel    string
+	}{
+		{
+			name:         "primary serves",
+			wantProvider: "openai",
+			wantModel:    "gpt-4o",
+		},
+		{
+			name:         "fails over on 5xx",
+			primaryErr:   unavailable,
+			wantProvider: "azure",
+			wantModel:    "gpt-4o",
+		},
+		{
+			name:         "fails over on rate limit",
+			primaryErr:   &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests},
+			wantProvider: "azure",
+			wantModel:    "gpt-4o",
+		},
+		{
+			name:         "fails over on network error",
+			primaryErr:   errors.New("connection refused"),
+			wantProvider: "azure",
+			wantModel:    "gpt-4o",
+		},
+		{
+			name:         "falls back to another model",
+			primaryErr:   unavailable,
+			secondaryErr: unavailable,
+			wantProvider: "local",
+			wantModel:    "llama3",
+		},
+		{
+			name:       "does not fail over rejected requests",
+			primaryErr: rejected,
+			wantErr:    rejected,
+		},
+		{
+			name:       "does not fail over cancelled requests",
+			primaryErr: unavailable,
+			ctx: func() context.Context {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx
+			},
+			wantErr: unavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := &scriptedClient{err: tt.primaryErr}
+			secondary := &scriptedClient{err: tt.secondaryErr}
+			local := &scriptedClient{}
+			registry := NewProviderRegistry()
+			require.NoError(t, registry.Register("openai", primary, []string{"gpt-4o"}))
+			require.NoError(t, registry.Register("azure", secondary, []string{"gpt-4"}))
+			require.NoError(t, registry.Register("local", local, []string{"llama3"}))
+			require.NoError(t, registry.SetFallbacks("gpt-4o", []config.FallbackTarget{{Provider: "azure"}, {Model: "llama3"}}))
+
+			ctx := context.Background()
+			if tt.ctx != nil {
+				ctx = tt.ctx()
+			}
+			stream, err := registry.CreateChatCompletionStream(ctx, openai.ChatCompletionRequest{Model: "gpt-4o"})
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				require.Empty(t, secondary.reqs)
+				return
+			}
+			require.NoError(t, err)
+			provider, model := servedBy(stream, "gpt-4o")
+			require.Equal(t, tt.wantProvider, provider)
+			require.Equal(t, tt.wantModel, model)
+		})
+	}
+}
+
+func TestProviderRegistry_SetFallbacks(t *testing.T) {
+	registry := NewProviderRegistry()
+	require.NoError(t, registry.Register("openai", &scriptedClient{}, []string{"gpt-4o"}))
+
+	require.EqualError(t, registry.SetFallbacks("gpt-4o", []config.FallbackTarget{{Provider: "azure"}}),
+		`fallback 0 of "gpt-4o": unknown provider "azure"`)
+	require.EqualError(t, registry.SetFallbacks("gpt-4o", []config.FallbackTarget{{Model: "llama3"}}),
+		`fallback 0 of "gpt-4o": no provider serves model "llama3"`)
+}
+
+func TestHandleChat_ReportsServingProvider(t *testing.T) {
+	primary := &scriptedClient{err: &openai.APIError{HTTPStatusCode: http.StatusBadGateway}}
+	secondary := &scriptedClient{streams: []*scriptedStream{replyStream("ok")}}
+	registry := NewProviderRegistry()
+	require.NoError(t, registry.Register("openrouter", primary, nil))
+	require.NoError(t, registry.Register("local", secondary, []string{"llama3"}))
+	require.NoError(t, registry.SetFallbacks("test/model", []config.FallbackTarget{{Model: "llama3"}}))
+	handler := NewChatHandler(registry, testConfig(), nil)
+
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Prompt: "hi"}))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	responses := collectResponses(t, w)
+	require.Equal(t, "connected", responses[0].Type)
+	require.Equal(t, "local", responses[0].Meta.Provider)
+	require.Equal(t, "llama3", responses[0].Meta.Model)
+	done := responses[len(responses)-1]
+	require.Equal(t, "done", done.Type)
+	require.Equal(t, &models.StreamMeta{Model: "llama3", Provider: "local"}, done.Meta)
+}
+
+func TestServedStream_Reasoning(t *testing.T) {
+	stream := &servedStream{ChatCompletionStreamer: &reasoningStream{reasoning: []string{"thinking"}, scriptedStream: scriptedStream{
+		chunks: []openai.ChatCompletionStreamResponse{contentChunk(0, "", "")},
+	}}}
+	_, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, "thinking", reasoningDelta(stream, 0))
+}
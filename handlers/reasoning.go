@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"encoding/json"
+)
+
+// ReasoningStreamer is implemented by streams that can report the reasoning
+// ("thinking") text of the chunk most recently returned by Recv. go-openai
+// has no field for it, so the client wrapper extracts it from the raw chunk.
+type ReasoningStreamer interface {
+	ChatCompletionStreamer
+	Reasoning(choice int) string
+}
+
+// reasoningDelta returns the reasoning text for a choice of the last chunk,
+// or "" when the stream does not expose reasoning
+func reasoningDelta(stream ChatCompletionStreamer, choice int) string {
+	if rs, ok := stream.(ReasoningStreamer); ok {
+		return rs.Reasoning(choice)
+	}
+	return ""
+}
+
+// ParseReasoning extracts per-choice reasoning deltas from a raw stream
+// chunk. OpenRouter sends them as delta.reasoning, DeepSeek-style providers
+// as delta.reasoning_content.
+func ParseReasoning(raw []byte) map[int]string {
+	var chunk struct {
+		Choices []struct {
+			Index int `json:"index"`
+			Delta struct {
+				Reasoning        string `json:"reasoning"`
+				ReasoningContent string `json:"reasoning_content"`
+			} `json:"delta"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(raw, &chunk); err != nil {
+		return nil
+	}
+
+	var reasoning map[int]string
+	for _, choice := range chunk.Choices {
+		text := choice.Delta.Reasoning
+		if text == "" {
+			text = choice.Delta.ReasoningContent
+		}
+		if text == "" {
+			continue
+		}
+		if reasoning == nil {
+			reasoning = make(map[int]string)
+		}
+		reasoning[choice.Index] = text
+	}
+	return reasoning
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"golang-ai-stream/models"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+// reasoningStream pairs each scripted chunk with the reasoning of its first choice
+type reasoningStream struct {
+	scriptedStream
+	reasoning []string
+	current   string
+}
+
+func (s *reasoningStream) Recv() (*openai.ChatCompletionStreamResponse, error) {
+	s.current = ""
+	if len(s.reasoning) > 0 {
+		s.current = s.reasoning[0]
+		s.reasoning = s.reasoning[1:]
+	}
+	return s.scriptedStream.Recv()
+}
+
+func (s *reasoningStream) Reasoning(choice int) string {
+	if choice != 0 {
+		return ""
+	}
+	return s.current
+}
+
+type reasoningClient struct {
+	stream *reasoningStream
+}
+
+func (c *reasoningClient) CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (ChatCompletionStreamer, error) {
+	return c.stream, nil
+}
+
+func thinkingClient() *reasoningClient {
+	return &reasoningClient{stream: &reasoningStream{
+		scriptedStream: scriptedStream{chunks: []openai.ChatCompletionStreamResponse{
+			contentChunk(0, "", ""),
+			contentChunk(0, "", ""),
+			contentChunk(0, "42", openai.FinishReasonStop),
+		}},
+		reasoning: []string{"Let me think.", " Six times seven.", ""},
+	}}
+}
+
+func TestParseReasoning(t *testing.T) {
+	raw := []byte(`{"choices":[
+		{"index":0,"delta":{"reasoning":"step one"}},
+		{"index":1,"delta":{"reasoning_content":"other"}},
+		{"index":2,"delta":{"content":"answer"}}
+	]}`)
+
+	require.Equal(t, map[int]string{0: "step one", 1: "other"}, ParseReasoning(raw))
+	require.Nil(t, ParseReasoning([]byte(`{"choices":[{"index":0,"delta":{"content":"x"}}]}`)))
+	require.Nil(t, ParseReasoning([]byte(`not json`)))
+}
+
+func TestHandleChat_Reasoning(t *testing.T) {
+	tests := []struct {
+		name      string
+		include   bool
+		wantTypes []string
+	}{
+		{name: "opted in", include: true, wantTypes: []string{"connected", "reasoning", "reasoning", "content", "usage", "done"}},
+		{name: "not requested", wantTypes: []string{"connected", "content", "usage", "done"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewChatHandler(thinkingClient(), testConfig(), nil)
+
+			w := httptest.NewRecorder()
+			handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Prompt: "6*7?", IncludeReasoning: tt.include}))
+
+			var types []string
+			var thinking, answer string
+			for _, resp := range collectResponses(t, w) {
+				types = append(types, resp.Type)
+				switch resp.Type {
+				case "reasoning":
+					thinking += resp.Content
+				case "content":
+					answer += resp.Content
+				}
+			}
+			require.Equal(t, tt.wantTypes, types)
+			require.Equal(t, "42", answer)
+			if tt.include {
+				require.Equal(t, "Let me think. Six times seven.", thinking)
+			}
+		})
+	}
+}
+
+func TestHandleChat_ReasoningJSON(t *testing.T) {
+	handler := NewChatHandler(thinkingClient(), testConfig(), nil)
+
+	stream := false
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Prompt: "6*7?", Stream: &stream, IncludeReasoning: true}))
+
+	var response models.ChatResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	require.Equal(t, "42", response.Content)
+	require.Equal(t, "Let me think. Six times seven.", response.Reasoning)
+}
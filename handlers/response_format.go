@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	apierrors "golang-ai-stream/errors"
+	"golang-ai-stream/models"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func validateResponseFormat(reqBody *models.ChatRequest) error {
+	format := reqBody.ResponseFormat
+	if format == nil {
+		return nil
+	}
+	switch openai.ChatCompletionResponseFormatType(format.Type) {
+	case openai.ChatCompletionResponseFormatTypeText, openai.ChatCompletionResponseFormatTypeJSONObject:
+		return nil
+	case openai.ChatCompletionResponseFormatTypeJSONSchema:
+	default:
+		return fmt.Errorf("response_format type %q is not supported", format.Type)
+	}
+
+	if format.JSONSchema == nil || format.JSONSchema.Name == "" {
+		return fmt.Errorf("response_format json_schema requires a name")
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(format.JSONSchema.Schema, &schema); err != nil {
+		return fmt.Errorf("response_format json_schema schema must be a JSON object")
+	}
+	if err := checkSchemaKeywords(schema); err != nil {
+		return fmt.Errorf("response_format json_schema schema: %v", err)
+	}
+	return nil
+}
+
+// applyResponseFormat forwards the requested response format upstream
+func applyResponseFormat(chatReq *openai.ChatCompletionRequest, reqBody *models.ChatRequest) {
+	format := reqBody.ResponseFormat
+	if format == nil {
+		return
+	}
+	chatReq.ResponseFormat = &openai.ChatCompletionResponseFormat{
+		Type: openai.ChatCompletionResponseFormatType(format.Type),
+	}
+	if format.JSONSchema != nil {
+		chatReq.ResponseFormat.JSONSchema = &openai.ChatCompletionResponseFormatJSONSchema{
+			Name:        format.JSONSchema.Name,
+			Description: format.JSONSchema.Description,
+			Schema:      format.JSONSchema.Schema,
+			Strict:      format.JSONSchema.Strict,
+		}
+	}
+}
+
+// checkStructuredOutput validates a finished reply against the requested
+// response format
+func checkStructuredOutput(format *models.ResponseFormat, output string) error {
+	if format == nil {
+		return nil
+	}
+	switch openai.ChatCompletionResponseFormatType(format.Type) {
+	case openai.ChatCompletionResponseFormatTypeJSONObject:
+		var object map[string]any
+		if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &object); err != nil {
+			return fmt.Errorf("output is not a JSON object")
+		}
+	case openai.ChatCompletionResponseFormatTypeJSONSchema:
+		return validateAgainstSchema(format.JSONSchema.Schema, output)
+	}
+	return nil
+}
+
+// repairMessages asks the model to correct a reply that failed validation
+func repairMessages(output string, err error) []openai.ChatCompletionMessage {
+	return []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleAssistant, Content: output},
+		{Role: openai.ChatMessageRoleUser, Content: fmt.Sprintf(
+			"Your response did not match the required format: %v. Reply again with only the corrected JSON.", err)},
+	}
+}
+
+// schemaErrorChunk builds the event sent instead of done when the final
+// output does not match the schema, or when a repair attempt starts
+func schemaErrorChunk(requestID, eventType string, err error) models.ChatResponse {
+	return models.ChatResponse{
+		Content:   err.Error(),
+		RequestID: requestID,
+		Type:      eventType,
+		Error:     &models.ErrorDetail{ErrorType: apierrors.TypeSchemaValidation},
+	}
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	apierrors "golang-ai-stream/errors"
+	"golang-ai-stream/models"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func personFormat(repair bool) *models.ResponseFormat {
+	return &models.ResponseFormat{
+		Type: "json_schema",
+		JSONSchema: &models.JSONSchema{
+			Name:   "person",
+			Schema: json.RawMessage(`{"type":"object","properties":{"name":{"type":"string"}},"required":["name"]}`),
+			Strict: true,
+		},
+		Repair: repair,
+	}
+}
+
+func replyStream(content string) *scriptedStream {
+	return &scriptedStream{chunks: []openai.ChatCompletionStreamResponse{contentChunk(0, content, openai.FinishReasonStop)}}
+}
+
+func TestValidateResponseFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  *models.ResponseFormat
+		wantErr string
+	}{
+		{name: "none"},
+		{name: "json object", format: &models.ResponseFormat{Type: "json_object"}},
+		{name: "json schema", format: personFormat(false)},
+		{name: "unknown type", format: &models.ResponseFormat{Type: "xml"}, wantErr: `response_format type "xml" is not supported`},
+		{name: "missing schema", format: &models.ResponseFormat{Type: "json_schema"}, wantErr: "response_format json_schema requires a name"},
+		{
+			name:    "schema not an object",
+			format:  &models.ResponseFormat{Type: "json_schema", JSONSchema: &models.JSONSchema{Name: "x", Schema: json.RawMessage(`[]`)}},
+			wantErr: "response_format json_schema schema must be a JSON object",
+		},
+		{
+			name:   "strict schema with format and bounds",
+			format: &models.ResponseFormat{Type: "json_schema", JSONSchema: &models.JSONSchema{Name: "x", Strict: true, Schema: json.RawMessage(`{"type":"object","properties":{"at":{"type":"string","format":"date-time"},"score":{"type":"number","exclusiveMinimum":0,"multipleOf":0.5},"tags":{"type":"array","uniqueItems":true}},"required":["at","score","tags"],"additionalProperties":false}`)}},
+		},
+		{
+			name:    "unsupported keyword",
+			format:  &models.ResponseFormat{Type: "json_schema", JSONSchema: &models.JSONSchema{Name: "x", Schema: json.RawMessage(`{"type":"object","properties":{"email":{"type":"string","if":{"minLength":1}}}}`)}},
+			wantErr: `response_format json_schema schema: keyword "if" at #/properties/email is not supported`,
+		},
+		{
+			name:    "unresolvable ref",
+			format:  &models.ResponseFormat{Type: "json_schema", JSONSchema: &models.JSONSchema{Name: "x", Schema: json.RawMessage(`{"$ref":"#/$defs/missing"}`)}},
+			wantErr: `response_format json_schema schema: #/$ref: $ref "#/$defs/missing" cannot be resolved`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateResponseFormat(&models.ChatRequest{ResponseFormat: tt.format})
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestApplyResponseFormat(t *testing.T) {
+	var chatReq openai.ChatCompletionRequest
+	applyResponseFormat(&chatReq, &models.ChatRequest{ResponseFormat: personFormat(true)})
+
+	payload, err := json.Marshal(chatReq)
+	require.NoError(t, err)
+	require.Contains(t, string(payload), `"response_format":{"type":"json_schema","json_schema":{"name":"person","schema":{"type":"object","properties":{"name":{"type":"string"}},"required":["name"]},"strict":true}}`)
+	require.NotContains(t, string(payload), "repair")
+}
+
+func TestHandleChat_StructuredOutput(t *testing.T) {
+	tests := []struct {
+		name      string
+		repair    bool
+		replies   []string
+		wantTypes []string
+		wantCalls int
+	}{
+		{
+			name:      "valid",
+			replies:   []string{`{"name":"Ada"}`},
+			wantTypes: []string{"connected", "content", "usage", "done"},
+			wantCalls: 1,
+		},
+		{
+			name:      "invalid",
+			replies:   []string{`{"nickname":"Ada"}`},
+			wantTypes: []string{"connected", "content", "usage", "error"},
+			wantCalls: 1,
+		},
+		{
+			name:      "repaired",
+			repair:    true,
+			replies:   []string{`{"nickname":"Ada"}`, `{"name":"Ada"}`},
+			wantTypes: []string{"connected", "content", "repair", "content", "usage", "done"},
+			wantCalls: 2,
+		},
+		{
+			name:      "repair fails",
+			repair:    true,
+			replies:   []string{`{"nickname":"Ada"}`, `not json`},
+			wantTypes: []string{"connected", "content", "repair", "content", "usage", "error"},
+			wantCalls: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &scriptedClient{}
+			for _, reply := range tt.replies {
+				client.streams = append(client.streams, replyStream(reply))
+			}
+			handler := NewChatHandler(client, testConfig(), nil)
+
+			w := httptest.NewRecorder()
+			handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Prompt: "who?", ResponseFormat: personFormat(tt.repair)}))
+
+			var types []string
+			responses := collectResponses(t, w)
+			for _, resp := range responses {
+				types = append(types, resp.Type)
+				if resp.Type == "repair" || resp.Type == "error" {
+					require.Equal(t, apierrors.TypeSchemaValidation, resp.Error.ErrorType)
+				}
+			}
+			require.Equal(t, tt.wantTypes, types)
+			require.Len(t, client.reqs, tt.wantCalls)
+			require.NotNil(t, client.reqs[0].ResponseFormat)
+		})
+	}
+}
+
+func TestHandleChat_StructuredOutputJSON(t *testing.T) {
+	stream := false
+	client := &scriptedClient{streams: []*scriptedStream{replyStream(`{"nickname":"Ada"}`)}}
+	handler := NewChatHandler(client, testConfig(), nil)
+
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Prompt: "who?", Stream: &stream, ResponseFormat: personFormat(false)}))
+
+	require.Equal(t, http.StatusBadGateway, w.Code)
+	var apiErr apierrors.APIError
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&apiErr))
+	require.Equal(t, apierrors.TypeSchemaValidation, apiErr.ErrorType)
+	require.Equal(t, `$: missing required property "name"`, apiErr.Message)
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"fmt"
+
+	"golang-ai-stream/config"
+	"golang-ai-stream/models"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const maxStopSequences = 4
+
+// samplingParams are the bounded sampling parameters of either request
+// shape; nil means the client left a parameter unset
+type samplingParams struct {
+	temperature      *float32
+	topP             *float32
+	presencePenalty  *float32
+	frequencyPenalty *float32
+	maxTokens        *int
+	stop             []string
+}
+
+func validateSampling(reqBody *models.ChatRequest, maxTokensLimit int) error {
+	return samplingParams{
+		temperature:      reqBody.Temperature,
+		topP:             reqBody.TopP,
+		presencePenalty:  reqBody.PresencePenalty,
+		frequencyPenalty: reqBody.FrequencyPenalty,
+		maxTokens:        reqBody.MaxTokens,
+		stop:             reqBody.Stop,
+	}.validate(maxTokensLimit)
+}
+
+// validateUpstreamSampling applies the same bounds to an OpenAI-shaped
+// request, where zero values mean unset
+func validateUpstreamSampling(chatReq *openai.ChatCompletionRequest, maxTokensLimit int) error {
+	params := samplingParams{
+		temperature:      &chatReq.Temperature,
+		topP:             &chatReq.TopP,
+		presencePenalty:  &chatReq.PresencePenalty,
+		frequencyPenalty: &chatReq.FrequencyPenalty,
+		stop:             chatReq.Stop,
+	}
+	if chatReq.MaxTokens != 0 {
+		params.maxTokens = &chatReq.MaxTokens
+	}
+	if err := params.validate(maxTokensLimit); err != nil {
+		return err
+	}
+	if chatReq.MaxCompletionTokens != 0 {
+		return samplingParams{maxTokens: &chatReq.MaxCompletionTokens}.validate(maxTokensLimit)
+	}
+	return nil
+}
+
+func (p samplingParams) validate(maxTokensLimit int) error {
+	if t := p.temperature; t != nil && (*t < 0 || *t > 2) {
+		return fmt.Errorf("temperature must be between 0 and 2")
+	}
+	if v := p.topP; v != nil && (*v < 0 || *v > 1) {
+		return fmt.Errorf("top_p must be between 0 and 1")
+	}
+	if v := p.presencePenalty; v != nil && (*v < -2 || *v > 2) {
+		return fmt.Errorf("presence_penalty must be between -2 and 2")
+	}
+	if v := p.frequencyPenalty; v != nil && (*v < -2 || *v > 2) {
+		return fmt.Errorf("frequency_penalty must be between -2 and 2")
+	}
+	if m := p.maxTokens; m != nil {
+		if *m < 1 {
+			return fmt.Errorf("max_tokens must be positive")
+		}
+		if maxTokensLimit > 0 && *m > maxTokensLimit {
+			return fmt.Errorf("max_tokens exceeds limit of %d", maxTokensLimit)
+		}
+	}
+	if len(p.stop) > maxStopSequences {
+		return fmt.Errorf("at most %d stop sequences are allowed", maxStopSequences)
+	}
+	return nil
+}
+
+// applySampling copies sampling parameters onto the upstream request, falling
+// back to the model defaults for anything the client left unset. go-openai
+// omits zero values, so an explicit 0 leaves the provider default in effect.
+func applySampling(chatReq *openai.ChatCompletionRequest, reqBody *models.ChatRequest, defaults config.SamplingDefaults) {
+	if v := firstFloat(reqBody.Temperature, defaults.Temperature); v != nil {
+		chatReq.Temperature = *v
+	}
+	if v := firstFloat(reqBody.TopP, defaults.TopP); v != nil {
+		chatReq.TopP = *v
+	}
+	if v := firstFloat(reqBody.PresencePenalty, defaults.PresencePenalty); v != nil {
+		chatReq.PresencePenalty = *v
+	}
+	if v := firstFloat(reqBody.FrequencyPenalty, defaults.FrequencyPenalty); v != nil {
+		chatReq.FrequencyPenalty = *v
+	}
+	if reqBody.MaxTokens != nil {
+		chatReq.MaxTokens = *reqBody.MaxTokens
+	} else if defaults.MaxTokens != nil {
+		chatReq.MaxTokens = *defaults.MaxTokens
+	}
+	chatReq.Stop = reqBody.Stop
+	chatReq.Seed = reqBody.Seed
+}
+
+func firstFloat(values ...*float32) *float32 {
+	for _, v := range values {
+		if v != nil {
+			return v
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"golang-ai-stream/config"
+	"golang-ai-stream/models"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func float32Ptr(v float32) *float32 { return &v }
+
+func intPtr(v int) *int { return &v }
+
+func TestValidateSampling(t *testing.T) {
+	tests := []struct {
+		name    string
+		reqBody models.ChatRequest
+		wantErr string
+	}{
+		{
+			name:    "no parameters",
+			reqBody: models.ChatRequest{},
+		},
+		{
+			name:    "valid parameters",
+			reqBody: models.ChatRequest{Temperature: float32Ptr(1), TopP: float32Ptr(0.9), MaxTokens: intPtr(100), Stop: []string{"\n"}},
+		},
+		{
+			name:    "temperature too high",
+			reqBody: models.ChatRequest{Temperature: float32Ptr(2.5)},
+			wantErr: "temperature must be between 0 and 2",
+		},
+		{
+			name:    "top_p out of range",
+			reqBody: models.ChatRequest{TopP: float32Ptr(-0.1)},
+			wantErr: "top_p must be between 0 and 1",
+		},
+		{
+			name:    "presence penalty out of range",
+			reqBody: models.ChatRequest{PresencePenalty: float32Ptr(3)},
+			wantErr: "presence_penalty must be between -2 and 2",
+		},
+		{
+			name:    "frequency penalty out of range",
+			reqBody: models.ChatRequest{FrequencyPenalty: float32Ptr(-3)},
+			wantErr: "frequency_penalty must be between -2 and 2",
+		},
+		{
+			name:    "max tokens not positive",
+			reqBody: models.ChatRequest{MaxTokens: intPtr(0)},
+			wantErr: "max_tokens must be positive",
+		},
+		{
+			name:    "max tokens over limit",
+			reqBody: models.ChatRequest{MaxTokens: intPtr(5000)},
+			wantErr: "max_tokens exceeds limit of 4096",
+		},
+		{
+			name:    "too many stop sequences",
+			reqBody: models.ChatRequest{Stop: []string{"a", "b", "c", "d", "e"}},
+			wantErr: "at most 4 stop sequences are allowed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSampling(&tt.reqBody, 4096)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestApplySampling(t *testing.T) {
+	defaults := config.SamplingDefaults{
+		Temperature: float32Ptr(0.2),
+		TopP:        float32Ptr(0.8),
+		MaxTokens:   intPtr(512),
+	}
+	reqBody := &models.ChatRequest{
+		Temperature: float32Ptr(1.1),
+		Stop:        []string{"END"},
+		Seed:        intPtr(42),
+	}
+
+	var chatReq openai.ChatCompletionRequest
+	applySampling(&chatReq, reqBody, defaults)
+
+	require.Equal(t, float32(1.1), chatReq.Temperature)
+	require.Equal(t, float32(0.8), chatReq.TopP)
+	require.Equal(t, 512, chatReq.MaxTokens)
+	require.Equal(t, []string{"END"}, chatReq.Stop)
+	require.Equal(t, 42, *chatReq.Seed)
+}
+
+func TestApplySampling_ExplicitZero(t *testing.T) {
+	defaults := config.SamplingDefaults{Temperature: float32Ptr(0.7)}
+	reqBody := &models.ChatRequest{Temperature: float32Ptr(0)}
+
+	chatReq := openai.ChatCompletionRequest{Model: "test/model"}
+	applySampling(&chatReq, reqBody, defaults)
+
+	body, err := json.Marshal(chatReq)
+	require.NoError(t, err)
+	var sent map[string]any
+	require.NoError(t, json.Unmarshal(body, &sent))
+
+	// The explicit 0 overrides the model default and is left to the provider
+	// default instead of being replaced by a non-zero stand-in
+	require.NotContains(t, sent, "temperature")
+}
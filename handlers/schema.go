@@ -0,0 +1,423 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// validateAgainstSchema checks a JSON document against a JSON schema. The
+// supported keywords cover what upstream structured output modes accept:
+// types, object properties and their counts, array items and uniqueness,
+// enum, const, composition with anyOf, allOf, oneOf and not, local $ref into
+// $defs or definitions, string patterns and numeric bounds. format is not
+// enforced. checkSchemaKeywords rejects schemas that use anything else
+// before the request is sent.
+func validateAgainstSchema(schema json.RawMessage, document string) error {
+	var root map[string]any
+	if err := json.Unmarshal(schema, &root); err != nil {
+		return fmt.Errorf("invalid schema: %v", err)
+	}
+	var value any
+	if err := json.Unmarshal([]byte(strings.TrimSpace(document)), &value); err != nil {
+		return fmt.Errorf("output is not valid JSON: %v", err)
+	}
+	return (&schemaValidator{root: root}).check(root, value, "$")
+}
+
+// maxSchemaDepth stops $ref cycles that never descend into the document
+const maxSchemaDepth = 128
+
+// schemaValidator resolves $ref against the root schema while validating
+type schemaValidator struct {
+	root  map[string]any
+	depth int
+}
+
+func (sv *schemaValidator) check(schema map[string]any, value any, path string) error {
+	sv.depth++
+	defer func() { sv.depth-- }()
+	if sv.depth > maxSchemaDepth {
+		return fmt.Errorf("%s: schema is nested too deeply", path)
+	}
+
+	if ref, ok := schema["$ref"].(string); ok {
+		target, err := resolveSchemaRef(sv.root, ref)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if err := sv.check(target, value, path); err != nil {
+			return err
+		}
+	}
+	if types, ok := schema["type"]; ok && !matchesType(types, value) {
+		return fmt.Errorf("%s: expected %s, got %s", path, describeTypes(types), jsonType(value))
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, candidate := range enum {
+			if reflect.DeepEqual(candidate, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: value is not one of the allowed values", path)
+		}
+	}
+	if constant, ok := schema["const"]; ok && !reflect.DeepEqual(constant, value) {
+		return fmt.Errorf("%s: value does not match the constant", path)
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok && sv.countMatches(anyOf, value, path) == 0 {
+		return fmt.Errorf("%s: value does not match any allowed schema", path)
+	}
+	if oneOf, ok := schema["oneOf"].([]any); ok && sv.countMatches(oneOf, value, path) != 1 {
+		return fmt.Errorf("%s: value must match exactly one allowed schema", path)
+	}
+	if allOf, ok := schema["allOf"].([]any); ok {
+		for _, option := range allOf {
+			if sub, ok := option.(map[string]any); ok {
+				if err := sv.check(sub, value, path); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if not, ok := schema["not"].(map[string]any); ok && sv.check(not, value, path) == nil {
+		return fmt.Errorf("%s: value matches a disallowed schema", path)
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		return sv.checkObject(schema, v, path)
+	case []any:
+		if min, ok := schema["minItems"].(float64); ok && float64(len(v)) < min {
+			return fmt.Errorf("%s: expected at least %v items", path, min)
+		}
+		if max, ok := schema["maxItems"].(float64); ok && float64(len(v)) > max {
+			return fmt.Errorf("%s: expected at most %v items", path, max)
+		}
+		// prefixItems constrain leading positions, items the rest
+		prefix, _ := schema["prefixItems"].([]any)
+		items, _ := schema["items"].(map[string]any)
+		for i, item := range v {
+			itemSchema := items
+			if i < len(prefix) {
+				itemSchema, _ = prefix[i].(map[string]any)
+			}
+			if itemSchema == nil {
+				continue
+			}
+			if err := sv.check(itemSchema, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		if unique, _ := schema["uniqueItems"].(bool); unique {
+			for i := range v {
+				for j := i + 1; j < len(v); j++ {
+					if reflect.DeepEqual(v[i], v[j]) {
+						return fmt.Errorf("%s: items %d and %d are equal", path, i, j)
+					}
+				}
+			}
+		}
+	case string:
+		length := len([]rune(v))
+		if min, ok := schema["minLength"].(float64); ok && float64(length) < min {
+			return fmt.Errorf("%s: expected at least %v characters", path, min)
+		}
+		if max, ok := schema["maxLength"].(float64); ok && float64(length) > max {
+			return fmt.Errorf("%s: expected at most %v characters", path, max)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("%s: invalid pattern: %v", path, err)
+			}
+			if !re.MatchString(v) {
+				return fmt.Errorf("%s: value does not match pattern %q", path, pattern)
+			}
+		}
+	case float64:
+		if min, ok := schema["minimum"].(float64); ok && v < min {
+			return fmt.Errorf("%s: expected a value of at least %v", path, min)
+		}
+		if max, ok := schema["maximum"].(float64); ok && v > max {
+			return fmt.Errorf("%s: expected a value of at most %v", path, max)
+		}
+		if min, ok := schema["exclusiveMinimum"].(float64); ok && v <= min {
+			return fmt.Errorf("%s: expected a value greater than %v", path, min)
+		}
+		if max, ok := schema["exclusiveMaximum"].(float64); ok && v >= max {
+			return fmt.Errorf("%s: expected a value less than %v", path, max)
+		}
+		if step, ok := schema["multipleOf"].(float64); ok && step > 0 {
+			quotient := v / step
+			if math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+				return fmt.Errorf("%s: expected a multiple of %v", path, step)
+			}
+		}
+	}
+	return nil
+}
+
+func (sv *schemaValidator) countMatches(options []any, value any, path string) int {
+	matches := 0
+	for _, option := range options {
+		if sub, ok := option.(map[string]any); ok && sv.check(sub, value, path) == nil {
+			matches++
+		}
+	}
+	return matches
+}
+
+func (sv *schemaValidator) checkObject(schema map[string]any, object map[string]any, path string) error {
+	if required, ok := schema["required"].([]any); ok {
+		for _, name := range required {
+			key, _ := name.(string)
+			if _, present := object[key]; !present {
+				return fmt.Errorf("%s: missing required property %q", path, key)
+			}
+		}
+	}
+
+	if min, ok := schema["minProperties"].(float64); ok && float64(len(object)) < min {
+		return fmt.Errorf("%s: expected at least %v properties", path, min)
+	}
+	if max, ok := schema["maxProperties"].(float64); ok && float64(len(object)) > max {
+		return fmt.Errorf("%s: expected at most %v properties", path, max)
+	}
+
+	properties, _ := schema["properties"].(map[string]any)
+	patterns, _ := schema["patternProperties"].(map[string]any)
+	for _, key := range sortedKeys(object) {
+		childPath := path + "." + key
+		matched := false
+		if property, ok := properties[key].(map[string]any); ok {
+			matched = true
+			if err := sv.check(property, object[key], childPath); err != nil {
+				return err
+			}
+		}
+		for _, pattern := range sortedKeys(patterns) {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("%s: invalid pattern: %v", path, err)
+			}
+			property, ok := patterns[pattern].(map[string]any)
+			if !ok || !re.MatchString(key) {
+				continue
+			}
+			matched = true
+			if err := sv.check(property, object[key], childPath); err != nil {
+				return err
+			}
+		}
+		if matched {
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				return fmt.Errorf("%s: property is not allowed", childPath)
+			}
+		case map[string]any:
+			if err := sv.check(additional, object[key], childPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func matchesType(types any, value any) bool {
+	switch t := types.(type) {
+	case string:
+		return matchesSingleType(t, value)
+	case []any:
+		for _, candidate := range t {
+			if name, ok := candidate.(string); ok && matchesSingleType(name, value) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func matchesSingleType(name string, value any) bool {
+	actual := jsonType(value)
+	if name == "number" && actual == "integer" {
+		return true
+	}
+	return name == actual
+}
+
+func jsonType(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return "unknown"
+}
+
+func describeTypes(types any) string {
+	if list, ok := types.([]any); ok {
+		names := make([]string, 0, len(list))
+		for _, name := range list {
+			names = append(names, fmt.Sprint(name))
+		}
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(types)
+}
+
+// resolveSchemaRef follows a local JSON pointer such as "#/$defs/address"
+func resolveSchemaRef(root map[string]any, ref string) (map[string]any, error) {
+	if ref != "#" && !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("$ref %q must point into the same schema", ref)
+	}
+	var node any = root
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#"), "/")[1:] {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		object, ok := node.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("$ref %q cannot be resolved", ref)
+		}
+		if node, ok = object[token]; !ok {
+			return nil, fmt.Errorf("$ref %q cannot be resolved", ref)
+		}
+	}
+	schema, ok := node.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("$ref %q does not point to a schema", ref)
+	}
+	return schema, nil
+}
+
+// schemaKeywords are the keywords the validator enforces, plus annotations
+// that do not constrain the output. format is an annotation by default in
+// JSON Schema and is left to the upstream structured output mode.
+var schemaKeywords = map[string]bool{
+	"type": true, "properties": true, "required": true, "additionalProperties": true,
+	"patternProperties": true, "minProperties": true, "maxProperties": true,
+	"items": true, "prefixItems": true, "minItems": true, "maxItems": true, "uniqueItems": true,
+	"enum": true, "const": true, "anyOf": true, "allOf": true, "oneOf": true, "not": true,
+	"$ref": true, "$defs": true, "definitions": true,
+	"minLength": true, "maxLength": true, "pattern": true,
+	"minimum": true, "maximum": true, "exclusiveMinimum": true, "exclusiveMaximum": true, "multipleOf": true,
+
+	"$schema": true, "$id": true, "$comment": true, "title": true, "description": true, "format": true,
+	"default": true, "examples": true, "deprecated": true, "readOnly": true, "writeOnly": true,
+}
+
+// checkSchemaKeywords rejects schemas the end-of-stream validation cannot
+// enforce, so that they are not silently treated as satisfied
+func checkSchemaKeywords(root map[string]any) error {
+	return walkSchemaKeywords(root, root, "#")
+}
+
+func walkSchemaKeywords(root, schema map[string]any, path string) error {
+	for _, key := range sortedKeys(schema) {
+		if !schemaKeywords[key] {
+			return fmt.Errorf("keyword %q at %s is not supported", key, path)
+		}
+		value := schema[key]
+		childPath := path + "/" + key
+		switch key {
+		case "properties", "$defs", "definitions":
+			children, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%s must be an object", childPath)
+			}
+			for _, name := range sortedKeys(children) {
+				if err := walkSubschema(root, children[name], childPath+"/"+name); err != nil {
+					return err
+				}
+			}
+		case "items", "not":
+			if err := walkSubschema(root, value, childPath); err != nil {
+				return err
+			}
+		case "additionalProperties":
+			if _, ok := value.(bool); !ok {
+				if err := walkSubschema(root, value, childPath); err != nil {
+					return err
+				}
+			}
+		case "patternProperties":
+			children, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%s must be an object", childPath)
+			}
+			for _, pattern := range sortedKeys(children) {
+				if _, err := regexp.Compile(pattern); err != nil {
+					return fmt.Errorf("%s/%s is not a valid pattern: %v", childPath, pattern, err)
+				}
+				if err := walkSubschema(root, children[pattern], childPath+"/"+pattern); err != nil {
+					return err
+				}
+			}
+		case "exclusiveMinimum", "exclusiveMaximum", "multipleOf":
+			if _, ok := value.(float64); !ok {
+				return fmt.Errorf("%s must be a number", childPath)
+			}
+		case "anyOf", "allOf", "oneOf", "prefixItems":
+			options, ok := value.([]any)
+			if !ok {
+				return fmt.Errorf("%s must be an array", childPath)
+			}
+			for i, option := range options {
+				if err := walkSubschema(root, option, childPath+"/"+strconv.Itoa(i)); err != nil {
+					return err
+				}
+			}
+		case "$ref":
+			ref, _ := value.(string)
+			if _, err := resolveSchemaRef(root, ref); err != nil {
+				return fmt.Errorf("%s: %v", childPath, err)
+			}
+		case "pattern":
+			pattern, _ := value.(string)
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("%s is not a valid pattern: %v", childPath, err)
+			}
+		}
+	}
+	return nil
+}
+
+func walkSubschema(root map[string]any, value any, path string) error {
+	schema, ok := value.(map[string]any)
+	if !ok {
+		return fmt.Errorf("%s must be a schema object", path)
+	}
+	return walkSchemaKeywords(root, schema, path)
+}
+
+func sortedKeys(object map[string]any) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
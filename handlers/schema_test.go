@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateAgainstSchema(t *testing.T) {
+	schema := json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string", "minLength": 1},
+			"age": {"type": "integer", "minimum": 0},
+			"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2},
+			"status": {"enum": ["active", "inactive"]},
+			"nickname": {"type": ["string", "null"]}
+		},
+		"required": ["name", "age"],
+		"additionalProperties": false
+	}`)
+
+	tests := []struct {
+		name     string
+		document string
+		wantErr  string
+	}{
+		{name: "valid", document: `{"name":"Ada","age":36,"tags":["math"],"status":"active","nickname":null}`},
+		{name: "not json", document: `{"name":`, wantErr: "output is not valid JSON: unexpected end of JSON input"},
+		{name: "wrong root type", document: `[]`, wantErr: "$: expected object, got array"},
+		{name: "missing required", document: `{"name":"Ada"}`, wantErr: `$: missing required property "age"`},
+		{name: "wrong property type", document: `{"name":"Ada","age":"old"}`, wantErr: "$.age: expected integer, got string"},
+		{name: "integer expected", document: `{"name":"Ada","age":1.5}`, wantErr: "$.age: expected integer, got number"},
+		{name: "below minimum", document: `{"name":"Ada","age":-1}`, wantErr: "$.age: expected a value of at least 0"},
+		{name: "too short", document: `{"name":"","age":1}`, wantErr: "$.name: expected at least 1 characters"},
+		{name: "bad item", document: `{"name":"Ada","age":1,"tags":[1]}`, wantErr: "$.tags[0]: expected string, got integer"},
+		{name: "too many items", document: `{"name":"Ada","age":1,"tags":["a","b","c"]}`, wantErr: "$.tags: expected at most 2 items"},
+		{name: "not in enum", document: `{"name":"Ada","age":1,"status":"gone"}`, wantErr: "$.status: value is not one of the allowed values"},
+		{name: "extra property", document: `{"name":"Ada","age":1,"email":"a@b.c"}`, wantErr: "$.email: property is not allowed"},
+		{name: "union type", document: `{"name":"Ada","age":1,"nickname":3}`, wantErr: "$.nickname: expected string or null, got integer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAgainstSchema(schema, tt.document)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestValidateAgainstSchema_Composition(t *testing.T) {
+	schema := json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"home": {"$ref": "#/$defs/address"},
+			"contact": {"oneOf": [
+				{"type": "string", "pattern": "^[^@]+@[^@]+$"},
+				{"type": "integer"}
+			]},
+			"label": {"allOf": [{"type": "string"}, {"not": {"const": "none"}}]}
+		},
+		"$defs": {
+			"address": {
+				"type": "object",
+				"properties": {"city": {"type": "string"}},
+				"required": ["city"]
+			}
+		}
+	}`)
+
+	tests := []struct {
+		name     string
+		document string
+		wantErr  string
+	}{
+		{name: "valid", document: `{"home":{"city":"Paris"},"contact":"a@b.c","label":"work"}`},
+		{name: "ref violated", document: `{"home":{}}`, wantErr: `$.home: missing required property "city"`},
+		{name: "no oneOf match", document: `{"contact":"nobody"}`, wantErr: "$.contact: value must match exactly one allowed schema"},
+		{name: "allOf violated", document: `{"label":3}`, wantErr: "$.label: expected string, got integer"},
+		{name: "not violated", document: `{"label":"none"}`, wantErr: "$.label: value matches a disallowed schema"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAgainstSchema(schema, tt.document)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestValidateAgainstSchema_RefCycle(t *testing.T) {
+	schema := json.RawMessage(`{"$ref": "#/$defs/a", "$defs": {"a": {"$ref": "#/$defs/a"}}}`)
+	require.EqualError(t, validateAgainstSchema(schema, `{}`), "$: schema is nested too deeply")
+}
+
+func TestCheckSchemaKeywords(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		wantErr string
+	}{
+		{name: "supported", schema: `{"type":"object","title":"t","properties":{"a":{"$ref":"#/definitions/a"}},"definitions":{"a":{"anyOf":[{"type":"string"}]}}}`},
+		{name: "unknown keyword", schema: `{"type":"object","if":{}}`, wantErr: `keyword "if" at # is not supported`},
+		{name: "upstream keywords", schema: `{"type":"object","format":"x","minProperties":1,"patternProperties":{"^x-":{"type":"string"}},"properties":{"p":{"prefixItems":[{"type":"integer"}],"uniqueItems":true},"n":{"exclusiveMaximum":10,"multipleOf":2}}}`},
+		{name: "nested unknown keyword", schema: `{"items":{"contains":{}}}`, wantErr: `keyword "contains" at #/items is not supported`},
+		{name: "boolean exclusive bound", schema: `{"exclusiveMinimum":true}`, wantErr: "#/exclusiveMinimum must be a number"},
+		{name: "invalid property pattern", schema: `{"patternProperties":{"(":{}}}`, wantErr: "#/patternProperties/( is not a valid pattern: error parsing regexp: missing closing ): `(`"},
+		{name: "tuple items", schema: `{"items":[{"type":"string"}]}`, wantErr: "#/items must be a schema object"},
+		{name: "remote ref", schema: `{"$ref":"https://example.com/schema.json"}`, wantErr: `#/$ref: $ref "https://example.com/schema.json" must point into the same schema`},
+		{name: "invalid pattern", schema: `{"pattern":"("}`, wantErr: "#/pattern is not a valid pattern: error parsing regexp: missing closing ): `(`"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var schema map[string]any
+			require.NoError(t, json.Unmarshal([]byte(tt.schema), &schema))
+			err := checkSchemaKeywords(schema)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestValidateAgainstSchema_UpstreamKeywords(t *testing.T) {
+	schema := json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"at": {"type": "string", "format": "date-time"},
+			"score": {"type": "number", "exclusiveMinimum": 0, "exclusiveMaximum": 10, "multipleOf": 0.5},
+			"tags": {"type": "array", "uniqueItems": true},
+			"point": {"type": "array", "prefixItems": [{"type": "number"}, {"type": "number"}], "items": {"type": "string"}}
+		},
+		"patternProperties": {"^x-": {"type": "string"}},
+		"additionalProperties": false,
+		"minProperties": 1,
+		"maxProperties": 4
+	}`)
+
+	tests := []struct {
+		name     string
+		document string
+		wantErr  string
+	}{
+		{name: "valid", document: `{"at":"not checked","score":2.5,"tags":["a","b"],"x-note":"hi"}`},
+		{name: "prefix and rest items", document: `{"point":[1,2,"label"]}`},
+		{name: "too few properties", document: `{}`, wantErr: "$: expected at least 1 properties"},
+		{name: "too many properties", document: `{"x-a":"1","x-b":"2","x-c":"3","x-d":"4","x-e":"5"}`, wantErr: "$: expected at most 4 properties"},
+		{name: "exclusive minimum", document: `{"score":0}`, wantErr: "$.score: expected a value greater than 0"},
+		{name: "exclusive maximum", document: `{"score":10}`, wantErr: "$.score: expected a value less than 10"},
+		{name: "not a multiple", document: `{"score":1.2}`, wantErr: "$.score: expected a multiple of 0.5"},
+		{name: "duplicate items", document: `{"tags":["a","a"]}`, wantErr: "$.tags: items 0 and 1 are equal"},
+		{name: "bad prefix item", document: `{"point":["a"]}`, wantErr: "$.point[0]: expected number, got string"},
+		{name: "bad rest item", document: `{"point":[1,2,3]}`, wantErr: "$.point[2]: expected string, got integer"},
+		{name: "pattern property", document: `{"x-note":1}`, wantErr: "$.x-note: expected string, got integer"},
+		{name: "unmatched property", document: `{"other":1}`, wantErr: "$.other: property is not allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAgainstSchema(schema, tt.document)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
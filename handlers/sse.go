@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang-ai-stream/models"
+)
+
+// sseWriter writes named SSE events with their buffer ids so EventSource
+// clients can dispatch by type and resume with Last-Event-ID
+type sseWriter struct {
+	mu          sync.Mutex
+	w           http.ResponseWriter
+	flusher     http.Flusher
+	retryMillis int
+	started     bool
+	// writeTimeout, when set, is the write deadline applied before each write
+	writeTimeout time.Duration
+}
+
+func newSSEWriter(w http.ResponseWriter, flusher http.Flusher, retryMillis int) *sseWriter {
+	return &sseWriter{
+		w:           w,
+		flusher:     flusher,
+		retryMillis: retryMillis,
+	}
+}
+
+// Send writes chunk as an event named after its type. An id of 0 omits the
+// id field for events that are not part of the resumable stream.
+func (s *sseWriter) Send(id int, chunk models.ChatResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chunkJSON, err := json.Marshal(chunk)
+	if err != nil {
+		return fmt.Errorf("error marshaling chunk: %v", err)
+	}
+	s.extendDeadline()
+
+	if !s.started && s.retryMillis > 0 {
+		if _, err := fmt.Fprintf(s.w, "retry: %d\n", s.retryMillis); err != nil {
+			return fmt.Errorf("error writing to response: %v", err)
+		}
+	}
+	s.started = true
+
+	if id > 0 {
+		if _, err := fmt.Fprintf(s.w, "id: %d\n", id); err != nil {
+			return fmt.Errorf("error writing to response: %v", err)
+		}
+	}
+	_, err = fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", chunk.Type, chunkJSON)
+	if err != nil {
+		return fmt.Errorf("error writing to response: %v", err)
+	}
+
+	s.flusher.Flush()
+	return nil
+}
+
+// Comment writes an SSE comment line, which clients ignore
+func (s *sseWriter) Comment(text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.extendDeadline()
+	if _, err := fmt.Fprintf(s.w, ": %s\n\n", text); err != nil {
+		return fmt.Errorf("error writing to response: %v", err)
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// extendDeadline moves the connection write deadline past the next write.
+// Writers that cannot set deadlines, such as test recorders, are left as is.
+func (s *sseWriter) extendDeadline() {
+	if s.writeTimeout > 0 {
+		http.NewResponseController(s.w).SetWriteDeadline(time.Now().Add(s.writeTimeout))
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang-ai-stream/middleware"
+	"golang-ai-stream/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSEWriter_Send(t *testing.T) {
+	w := httptest.NewRecorder()
+	sse := newSSEWriter(w, w, 3000)
+
+	require.NoError(t, sse.Send(1, models.ChatResponse{Content: "Hi", RequestID: "test-id", Type: "content"}))
+	require.NoError(t, sse.Send(2, models.ChatResponse{RequestID: "test-id", Type: "done"}))
+
+	expected := "retry: 3000\n" +
+		"id: 1\nevent: content\ndata: {\"content\":\"Hi\",\"request_id\":\"test-id\",\"type\":\"content\"}\n\n" +
+		"id: 2\nevent: done\ndata: {\"content\":\"\",\"request_id\":\"test-id\",\"type\":\"done\"}\n\n"
+	require.Equal(t, expected, w.Body.String())
+}
+
+func TestSSEWriter_NoRetryOrID(t *testing.T) {
+	w := httptest.NewRecorder()
+	sse := newSSEWriter(w, w, 0)
+
+	require.NoError(t, sse.Send(0, models.ChatResponse{Type: "error"}))
+	require.NotContains(t, w.Body.String(), "retry:")
+	require.NotContains(t, w.Body.String(), "id:")
+}
+
+func TestSSEWriter_Comment(t *testing.T) {
+	w := httptest.NewRecorder()
+	sse := newSSEWriter(w, w, 0)
+
+	require.NoError(t, sse.Comment("ping"))
+	require.Equal(t, ": ping\n\n", w.Body.String())
+}
+
+func TestSSEWriter_ExtendsWriteDeadline(t *testing.T) {
+	// The stream outlives the server WriteTimeout, but no single write does
+	handler := middleware.Logger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sse := newSSEWriter(w, w.(http.Flusher), 0)
+		sse.writeTimeout = 200 * time.Millisecond
+		for i := 1; i <= 4; i++ {
+			time.Sleep(100 * time.Millisecond)
+			if i%2 == 0 && sse.Comment("ping") != nil {
+				return
+			}
+			if sse.Send(i, models.ChatResponse{Type: "content"}) != nil {
+				return
+			}
+		}
+	}))
+	server := httptest.NewUnstartedServer(handler)
+	server.Config.WriteTimeout = 200 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, 4, strings.Count(string(body), "event: content"))
+}
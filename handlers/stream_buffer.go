@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+
+	"golang-ai-stream/models"
+)
+
+// streamBuffer records the events of one chat stream so that reconnecting
+// clients can replay what they missed and keep tailing. Event ids are the
+// 1-based position in the buffer.
+type streamBuffer struct {
+	mu     sync.Mutex
+	events []models.ChatResponse
+	done   bool
+	notify chan struct{}
+
+	// readers counts attached clients. When none has been attached for grace
+	// while the stream is still running, it ends with final and abandon is
+	// called.
+	readers int
+	grace   time.Duration
+	final   models.ChatResponse
+	abandon func()
+	idle    *time.Timer
+}
+
+func newStreamBuffer() *streamBuffer {
+	return &streamBuffer{notify: make(chan struct{})}
+}
+
+// Append records an event and wakes up any waiting readers
+func (b *streamBuffer) Append(chunk models.ChatResponse) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.done {
+		return
+	}
+	b.events = append(b.events, chunk)
+	close(b.notify)
+	b.notify = make(chan struct{})
+}
+
+// Close marks the stream as finished; no further events will be appended
+func (b *streamBuffer) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.done {
+		return
+	}
+	b.done = true
+	close(b.notify)
+	if b.idle != nil {
+		b.idle.Stop()
+	}
+}
+
+// abandonAfter ends the stream with final and calls abandon once no reader
+// has been attached for grace, so that generations nobody will resume are
+// stopped
+func (b *streamBuffer) abandonAfter(grace time.Duration, final models.ChatResponse, abandon func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.grace = grace
+	b.final = final
+	b.abandon = abandon
+}
+
+// Attach registers a reader and returns the function that detaches it
+func (b *streamBuffer) Attach() (detach func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.readers++
+	if b.idle != nil {
+		b.idle.Stop()
+		b.idle = nil
+	}
+	return b.detach
+}
+
+func (b *streamBuffer) detach() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.readers--
+	if b.readers > 0 || b.done || b.abandon == nil {
+		return
+	}
+	b.idle = time.AfterFunc(b.grace, func() {
+		b.mu.Lock()
+		abandoned := b.readers == 0 && !b.done
+		if abandoned {
+			b.events = append(b.events, b.final)
+			b.done = true
+			close(b.notify)
+		}
+		b.mu.Unlock()
+		if abandoned {
+			b.abandon()
+		}
+	})
+}
+
+// Since returns the events after lastID, whether the stream has finished and
+// a channel that is closed when more events arrive
+func (b *streamBuffer) Since(lastID int) ([]models.ChatResponse, bool, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if lastID < 0 {
+		lastID = 0
+	}
+	var events []models.ChatResponse
+	if lastID < len(b.events) {
+		events = append(events, b.events[lastID:]...)
+	}
+	return events, b.done, b.notify
+}
+
+// StreamStore keeps stream buffers addressable by a server-generated stream
+// id for a retention window after the stream finishes
+type StreamStore struct {
+	mu        sync.Mutex
+	buffers   map[string]*streamBuffer
+	retention time.Duration
+}
+
+func NewStreamStore(retention time.Duration) *StreamStore {
+	return &StreamStore{
+		buffers:   make(map[string]*streamBuffer),
+		retention: retention,
+	}
+}
+
+// create registers a new buffer under streamID; it refuses ids that are
+// already in use so that one stream can never replace another
+func (s *StreamStore) create(streamID string) (*streamBuffer, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.buffers[streamID]; exists {
+		return nil, false
+	}
+	buf := newStreamBuffer()
+	s.buffers[streamID] = buf
+	return buf, true
+}
+
+func (s *StreamStore) get(streamID string) (*streamBuffer, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	buf, ok := s.buffers[streamID]
+	return buf, ok
+}
+
+// finish closes the buffer and schedules its removal after the retention window
+func (s *StreamStore) finish(streamID string, buf *streamBuffer) {
+	buf.Close()
+	time.AfterFunc(s.retention, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.buffers[streamID] == buf {
+			delete(s.buffers, streamID)
+		}
+	})
+}
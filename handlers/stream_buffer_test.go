@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang-ai-stream/config"
+	"golang-ai-stream/middleware"
+	"golang-ai-stream/models"
+
+	"github.com/gorilla/mux"
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamBuffer(t *testing.T) {
+	buf := newStreamBuffer()
+
+	events, done, wait := buf.Since(0)
+	require.Empty(t, events)
+	require.False(t, done)
+
+	buf.Append(models.ChatResponse{Content: "a", Type: "content"})
+	buf.Append(models.ChatResponse{Content: "b", Type: "content"})
+
+	select {
+	case <-wait:
+	default:
+		t.Fatal("expected waiters to be notified on append")
+	}
+
+	events, done, _ = buf.Since(1)
+	require.Len(t, events, 1)
+	require.Equal(t, "b", events[0].Content)
+	require.False(t, done)
+
+	buf.Close()
+	buf.Append(models.ChatResponse{Content: "ignored", Type: "content"})
+
+	events, done, _ = buf.Since(0)
+	require.Len(t, events, 2)
+	require.True(t, done)
+}
+
+func TestStreamStore_Expiry(t *testing.T) {
+	store := NewStreamStore(10 * time.Millisecond)
+	buf, ok := store.create("req-1")
+	require.True(t, ok)
+
+	_, ok = store.get("req-1")
+	require.True(t, ok)
+
+	store.finish("req-1", buf)
+	require.Eventually(t, func() bool {
+		_, ok := store.get("req-1")
+		return !ok
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestStreamStore_RefusesExistingID(t *testing.T) {
+	store := NewStreamStore(time.Minute)
+	first, ok := store.create("stream-1")
+	require.True(t, ok)
+
+	_, ok = store.create("stream-1")
+	require.False(t, ok)
+
+	buf, ok := store.get("stream-1")
+	require.True(t, ok)
+	require.Same(t, first, buf)
+}
+
+func TestHandleChat_ReusedRequestIDGetsOwnStream(t *testing.T) {
+	client := &scriptedClient{streams: []*scriptedStream{
+		{chunks: []openai.ChatCompletionStreamResponse{contentChunk(0, "first", openai.FinishReasonStop)}},
+		{chunks: []openai.ChatCompletionStreamResponse{contentChunk(0, "second", openai.FinishReasonStop)}},
+	}}
+	cfg := testConfig()
+	cfg.StreamBufferSecs = 60
+	handler := NewChatHandler(client, cfg, nil)
+
+	// Both requests carry the same client-supplied X-Request-ID
+	var streamIDs []string
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Prompt: "hi"}))
+		connected := collectResponses(t, w)[0]
+		require.Equal(t, "test-id", connected.RequestID)
+		require.NotEmpty(t, connected.Meta.StreamID)
+		streamIDs = append(streamIDs, connected.Meta.StreamID)
+	}
+	require.NotEqual(t, streamIDs[0], streamIDs[1])
+
+	for i, want := range []string{"first", "second"} {
+		buf, ok := handler.streams.get(streamIDs[i])
+		require.True(t, ok)
+		events, _, _ := buf.Since(1)
+		require.Equal(t, want, events[0].Content)
+	}
+}
+
+func TestHandleStreamResume(t *testing.T) {
+	client := &scriptedClient{streams: []*scriptedStream{{chunks: []openai.ChatCompletionStreamResponse{
+		contentChunk(0, "Hel", ""),
+		contentChunk(0, "lo", openai.FinishReasonStop),
+	}}}}
+	cfg := testConfig()
+	cfg.StreamBufferSecs = 60
+	handler := NewChatHandler(client, cfg, nil)
+
+	// The original client disconnects before reading anything
+	req := newTestRequest(t, "/chat", models.ChatRequest{Prompt: "hi"})
+	ctx, cancel := context.WithCancel(req.Context())
+	cancel()
+	original := httptest.NewRecorder()
+	handler.HandleChat(original, req.WithContext(ctx))
+	streamID := collectResponses(t, original)[0].Meta.StreamID
+	require.NotEmpty(t, streamID)
+
+	resume := func(lastEventID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/chat/"+streamID+"/stream", nil)
+		req = req.WithContext(context.WithValue(req.Context(), middleware.RequestIDKey, "resume-id"))
+		req = mux.SetURLVars(req, map[string]string{"stream_id": streamID})
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		w := httptest.NewRecorder()
+		handler.HandleStreamResume(w, req)
+		return w
+	}
+
+	w := resume("3")
+	require.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	responses := collectResponses(t, w)
+	require.Len(t, responses, 2)
+	require.Equal(t, "usage", responses[0].Type)
+	require.Equal(t, "done", responses[1].Type)
+	require.Contains(t, body, "id: 4\n")
+	require.NotContains(t, body, "id: 3\n")
+
+	require.Len(t, collectResponses(t, resume("")), 5)
+	require.Equal(t, http.StatusBadRequest, resume("abc").Code)
+}
+
+func TestHandleStreamResume_NotFound(t *testing.T) {
+	handler := NewChatHandler(&scriptedClient{}, &config.Config{StreamBufferSecs: 60}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/chat/missing/stream", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.RequestIDKey, "resume-id"))
+	req = mux.SetURLVars(req, map[string]string{"stream_id": "missing"})
+	w := httptest.NewRecorder()
+	handler.HandleStreamResume(w, req)
+
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// blockingClient returns streams that stall until their context is cancelled
+type blockingClient struct {
+	cancelled chan struct{}
+}
+
+func (c *blockingClient) CreateChatCompletionStream(ctx context.Context, req openai.ChatCompletionRequest) (ChatCompletionStreamer, error) {
+	return &blockingStream{ctx: ctx, cancelled: c.cancelled}, nil
+}
+
+type blockingStream struct {
+	ctx       context.Context
+	cancelled chan struct{}
+}
+
+func (s *blockingStream) Recv() (*openai.ChatCompletionStreamResponse, error) {
+	<-s.ctx.Done()
+	close(s.cancelled)
+	return nil, s.ctx.Err()
+}
+
+func (s *blockingStream) Close() {}
+
+func TestHandleChat_AbandonedStreamIsCancelled(t *testing.T) {
+	client := &blockingClient{cancelled: make(chan struct{})}
+	cfg := testConfig()
+	cfg.StreamBufferSecs = 60
+	handler := NewChatHandler(client, cfg, nil)
+	handler.streams = NewStreamStore(20 * time.Millisecond)
+
+	// The client disconnects and never resumes the stalled generation
+	req := newTestRequest(t, "/chat", models.ChatRequest{Prompt: "hi"})
+	ctx, cancel := context.WithCancel(req.Context())
+	cancel()
+	original := httptest.NewRecorder()
+	handler.HandleChat(original, req.WithContext(ctx))
+	streamID := collectResponses(t, original)[0].Meta.StreamID
+
+	select {
+	case <-client.cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("abandoned generation was not cancelled")
+	}
+
+	buf, ok := handler.streams.get(streamID)
+	require.True(t, ok)
+	events, done, _ := buf.Since(0)
+	require.True(t, done)
+	last := events[len(events)-1]
+	require.Equal(t, "error", last.Type)
+	require.Equal(t, "client_disconnected", last.Error.ErrorType)
+}
+
+func TestStreamBuffer_AbandonAfter(t *testing.T) {
+	buf := newStreamBuffer()
+	abandoned := make(chan struct{})
+	buf.abandonAfter(30*time.Millisecond, models.ChatResponse{Type: "error"}, func() { close(abandoned) })
+
+	// A reader re-attaching within the grace period keeps the stream alive
+	buf.Attach()()
+	time.Sleep(10 * time.Millisecond)
+	detach := buf.Attach()
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case <-abandoned:
+		t.Fatal("stream abandoned while a reader was attached")
+	default:
+	}
+
+	detach()
+	select {
+	case <-abandoned:
+	case <-time.After(time.Second):
+		t.Fatal("stream was not abandoned")
+	}
+	events, done, _ := buf.Since(0)
+	require.True(t, done)
+	require.Equal(t, []models.ChatResponse{{Type: "error"}}, events)
+}
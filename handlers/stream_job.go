@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"golang-ai-stream/logger"
+	"golang-ai-stream/models"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// continuePrompt asks the model to pick up a reply that was cut off by the
+// token limit
+const continuePrompt = "Continue exactly where you left off, without repeating anything."
+
+// streamJob is the state of one /chat generation, which may span several
+// upstream calls when the reply is automatically continued
+type streamJob struct {
+	requestID    string
+	chatReq      openai.ChatCompletionRequest
+	tracker      *usageTracker
+	autoContinue bool
+	format       *models.ResponseFormat
+	reasoning    bool
+	// branch is set when the reply is stored in a conversation
+	branch *conversationBranch
+	// contextTrim reports history trimmed to fit the context window
+	contextTrim *models.ContextTrim
+	// provider served the first upstream call, for chatReq.Model
+	provider string
+}
+
+// servedMeta reports the backend that served the generation on done events
+func (j *streamJob) servedMeta() *models.StreamMeta {
+	return &models.StreamMeta{Model: j.chatReq.Model, Provider: j.provider}
+}
+
+// pumpStream reads upstream streams into buf until the generation ends,
+// re-invoking the upstream when a length-truncated reply should be continued
+// or after running the server-side tools the model called. Structured output
+// is validated once the reply is complete and may be repaired once. JSON mode
+// runs the same loop and aggregates buf afterwards.
+func (h *ChatHandler) pumpStream(ctx context.Context, stream ChatCompletionStreamer, buf *streamBuffer, job *streamJob) {
+	messages := job.chatReq.Messages
+	continuations := 0
+	toolIterations := 0
+	repaired := false
+	var output strings.Builder
+
+	for {
+		result, err := job.readStream(stream, buf)
+		stream.Close()
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return // The client went away and generation was not detached
+			}
+			logger.LogError(job.requestID, err, "Failed to receive chat completion")
+			buf.Append(streamErrorChunk(job.requestID, err))
+			return
+		}
+		// Continuations, tool calls and repairs extend a single conversation,
+		// so they only apply when one choice was requested
+		first := result[0]
+		output.WriteString(first.content)
+
+		if len(result) == 1 && first.finishReason == openai.FinishReasonLength && job.autoContinue && continuations < h.config.MaxContinuations {
+			continuations++
+			job.chatReq.Messages = append(job.chatReq.Messages,
+				openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: first.content},
+				openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: continuePrompt},
+			)
+			stream, err = h.client.CreateChatCompletionStream(ctx, job.chatReq)
+			if err != nil {
+				logger.LogError(job.requestID, err, "Error creating continuation stream")
+				buf.Append(streamErrorChunk(job.requestID, err))
+				return
+			}
+			continue
+		}
+
+		if len(result) == 1 && h.tools.HandlesAll(first.toolCalls) {
+			if toolIterations >= h.config.MaxToolIterations {
+				logger.LogError(job.requestID, errToolLoopExceeded, "Tool loop stopped")
+				buf.Append(streamErrorChunk(job.requestID, errToolLoopExceeded))
+				return
+			}
+			toolIterations++
+			toolMessages, results := h.runTools(ctx, first.content, first.toolCalls)
+			for i := range results {
+				buf.Append(models.ChatResponse{
+					Content:    "",
+					RequestID:  job.requestID,
+					Type:       "tool_result",
+					ToolResult: &results[i],
+				})
+			}
+			job.chatReq.Messages = append(job.chatReq.Messages, toolMessages...)
+			output.Reset()
+			stream, err = h.client.CreateChatCompletionStream(ctx, job.chatReq)
+			if err != nil {
+				logger.LogError(job.requestID, err, "Error creating stream after tool calls")
+				buf.Append(streamErrorChunk(job.requestID, err))
+				return
+			}
+			continue
+		}
+
+		if len(result) == 1 {
+			if invalid := checkStructuredOutput(job.format, output.String()); invalid != nil && job.format.Repair && !repaired {
+				repaired = true
+				buf.Append(schemaErrorChunk(job.requestID, "repair", invalid))
+				job.chatReq.Messages = append(job.chatReq.Messages, repairMessages(output.String(), invalid)...)
+				output.Reset()
+				stream, err = h.client.CreateChatCompletionStream(ctx, job.chatReq)
+				if err != nil {
+					logger.LogError(job.requestID, err, "Error creating repair stream")
+					buf.Append(streamErrorChunk(job.requestID, err))
+					return
+				}
+				continue
+			}
+			result[0].content = output.String()
+		}
+
+		buf.Append(models.ChatResponse{
+			Content:   "",
+			RequestID: job.requestID,
+			Type:      "usage",
+			Usage:     job.tracker.Usage(messages),
+		})
+		// Every choice ends with its own done event, or an error event when
+		// its output does not match the requested response format
+		for _, choice := range result {
+			if invalid := checkStructuredOutput(job.format, choice.content); invalid != nil {
+				logger.LogError(job.requestID, invalid, "Structured output validation failed")
+				chunk := schemaErrorChunk(job.requestID, "error", invalid)
+				chunk.Index = choiceIndex(choice.index)
+				buf.Append(chunk)
+				continue
+			}
+			done := models.ChatResponse{
+				Content:      "",
+				RequestID:    job.requestID,
+				Type:         "done",
+				Index:        choiceIndex(choice.index),
+				Meta:         job.servedMeta(),
+				FinishReason: string(choice.finishReason),
+				ToolCalls:    choice.toolCalls,
+			}
+			h.saveConversation(ctx, job, choice.reply(), &done)
+			buf.Append(done)
+		}
+		return
+	}
+}
+
+// choiceResult is what one upstream stream produced for one choice
+type choiceResult struct {
+	index        int
+	content      string
+	finishReason openai.FinishReason
+	toolCalls    []models.ToolCall
+}
+
+// choiceState accumulates the deltas of one choice
+type choiceState struct {
+	content      strings.Builder
+	finishReason openai.FinishReason
+	toolCalls    *toolCallAssembler
+}
+
+// reply is the assistant turn stored for this choice
+func (c choiceResult) reply() models.Message {
+	return models.Message{Role: openai.ChatMessageRoleAssistant, Content: c.content, ToolCalls: c.toolCalls}
+}
+
+func choiceIndex(index int) *int {
+	return &index
+}
+
+// readStream forwards one upstream stream into buf until EOF, emitting
+// content, reasoning and incremental tool_call events tagged with their
+// choice index. It returns one result per requested choice, in index order.
+func (j *streamJob) readStream(stream ChatCompletionStreamer, buf *streamBuffer) ([]choiceResult, error) {
+	n := 1
+	if j.chatReq.N > 1 {
+		n = j.chatReq.N
+	}
+	states := make([]*choiceState, n)
+	for i := range states {
+		states[i] = &choiceState{toolCalls: newToolCallAssembler()}
+	}
+
+	for {
+		response, err := stream.Recv()
+		if err == io.EOF {
+			results := make([]choiceResult, n)
+			for i, state := range states {
+				results[i] = choiceResult{
+					index:        i,
+					content:      state.content.String(),
+					finishReason: state.finishReason,
+					toolCalls:    state.toolCalls.Calls(),
+				}
+			}
+			return results, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		j.tracker.Observe(response)
+		// The usage chunk requested via stream_options carries no choices
+		for _, choice := range response.Choices {
+			if choice.Index < 0 || choice.Index >= n {
+				continue
+			}
+			j.readChoice(stream, buf, states[choice.Index], choice)
+		}
+	}
+}
+
+func (j *streamJob) readChoice(stream ChatCompletionStreamer, buf *streamBuffer, state *choiceState, choice openai.ChatCompletionStreamChoice) {
+	if choice.FinishReason != "" {
+		state.finishReason = choice.FinishReason
+	}
+
+	if j.reasoning {
+		if text := reasoningDelta(stream, choice.Index); text != "" {
+			buf.Append(models.ChatResponse{
+				Content:   text,
+				RequestID: j.requestID,
+				Type:      "reasoning",
+				Index:     choiceIndex(choice.Index),
+			})
+		}
+	}
+
+	for _, delta := range state.toolCalls.Add(choice.Delta.ToolCalls) {
+		buf.Append(models.ChatResponse{
+			Content:   "",
+			RequestID: j.requestID,
+			Type:      "tool_call",
+			Index:     choiceIndex(choice.Index),
+			ToolCall:  &delta,
+		})
+	}
+
+	if choice.Delta.Content == "" {
+		return
+	}
+	state.content.WriteString(choice.Delta.Content)
+	buf.Append(models.ChatResponse{
+		Content:   choice.Delta.Content,
+		RequestID: j.requestID,
+		Type:      "content",
+		Index:     choiceIndex(choice.Index),
+	})
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"golang-ai-stream/models"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPumpStream_FinishReason(t *testing.T) {
+	client := &scriptedClient{streams: []*scriptedStream{{chunks: []openai.ChatCompletionStreamResponse{
+		contentChunk(0, "Once upon", openai.FinishReasonLength),
+	}}}}
+	handler := NewChatHandler(client, testConfig(), nil)
+
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Prompt: "tell a story"}))
+
+	responses := collectResponses(t, w)
+	done := responses[len(responses)-1]
+	require.Equal(t, "done", done.Type)
+	require.Equal(t, "length", done.FinishReason)
+	require.Len(t, client.reqs, 1, "should not continue unless requested")
+}
+
+func TestPumpStream_AutoContinue(t *testing.T) {
+	client := &scriptedClient{streams: []*scriptedStream{
+		{chunks: []openai.ChatCompletionStreamResponse{contentChunk(0, "Once upon", openai.FinishReasonLength)}},
+		{chunks: []openai.ChatCompletionStreamResponse{contentChunk(0, " a time", openai.FinishReasonStop)}},
+	}}
+	cfg := testConfig()
+	cfg.MaxContinuations = 2
+	handler := NewChatHandler(client, cfg, nil)
+
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Prompt: "tell a story", AutoContinue: true}))
+
+	var content string
+	responses := collectResponses(t, w)
+	for _, resp := range responses {
+		if resp.Type == "content" {
+			content += resp.Content
+		}
+	}
+	require.Equal(t, "Once upon a time", content)
+	require.Equal(t, "stop", responses[len(responses)-1].FinishReason)
+
+	require.Len(t, client.reqs, 2)
+	followUp := client.reqs[1].Messages
+	require.Len(t, followUp, 3)
+	require.Equal(t, openai.ChatMessageRoleAssistant, followUp[1].Role)
+	require.Equal(t, "Once upon", followUp[1].Content)
+	require.Equal(t, continuePrompt, followUp[2].Content)
+}
+
+func TestPumpStream_AutoContinueLimit(t *testing.T) {
+	truncated := func() *scriptedStream {
+		return &scriptedStream{chunks: []openai.ChatCompletionStreamResponse{contentChunk(0, "more", openai.FinishReasonLength)}}
+	}
+	client := &scriptedClient{streams: []*scriptedStream{truncated(), truncated(), truncated()}}
+	cfg := testConfig()
+	cfg.MaxContinuations = 1
+	handler := NewChatHandler(client, cfg, nil)
+
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Prompt: "go on", AutoContinue: true}))
+
+	responses := collectResponses(t, w)
+	require.Equal(t, "length", responses[len(responses)-1].FinishReason)
+	require.Len(t, client.reqs, 2)
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"golang-ai-stream/models"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// templateExtensions are the files loaded as system prompt templates
+var templateExtensions = map[string]bool{
+	".txt":  true,
+	".md":   true,
+	".tmpl": true,
+}
+
+// PromptTemplates holds named system prompts loaded from a directory. A
+// template's ID is its file name without the extension, and variables are
+// referenced as {{.name}}.
+type PromptTemplates struct {
+	templates map[string]*template.Template
+}
+
+// LoadPromptTemplates parses every template file in dir. An empty dir yields
+// no templates.
+func LoadPromptTemplates(dir string) (*PromptTemplates, error) {
+	templates := &PromptTemplates{templates: make(map[string]*template.Template)}
+	if dir == "" {
+		return templates, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt templates: %v", err)
+	}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || !templateExtensions[ext] {
+			continue
+		}
+		id := strings.TrimSuffix(entry.Name(), ext)
+		if _, exists := templates.templates[id]; exists {
+			return nil, fmt.Errorf("duplicate prompt template %q", id)
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt template %q: %v", id, err)
+		}
+		tmpl, err := template.New(id).Option("missingkey=error").Parse(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("invalid prompt template %q: %v", id, err)
+		}
+		templates.templates[id] = tmpl
+	}
+	return templates, nil
+}
+
+// IDs returns the loaded template IDs in sorted order
+func (p *PromptTemplates) IDs() []string {
+	if p == nil {
+		return nil
+	}
+	ids := make([]string, 0, len(p.templates))
+	for id := range p.templates {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Render executes the template with the given variables. Referencing a
+// variable that was not provided is an error.
+func (p *PromptTemplates) Render(id string, variables map[string]string) (string, error) {
+	var tmpl *template.Template
+	if p != nil {
+		tmpl = p.templates[id]
+	}
+	if tmpl == nil {
+		return "", fmt.Errorf("unknown template %q", id)
+	}
+
+	if variables == nil {
+		variables = map[string]string{}
+	}
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, variables); err != nil {
+		return "", fmt.Errorf("failed to render template %q: %v", id, err)
+	}
+	return strings.TrimSpace(rendered.String()), nil
+}
+
+// WithPromptTemplates sets the templates requests may select by ID
+func (h *ChatHandler) WithPromptTemplates(templates *PromptTemplates) *ChatHandler {
+	h.templates = templates
+	return h
+}
+
+// applyTemplate renders the requested template and prepends it to the
+// conversation as a system message
+func (h *ChatHandler) applyTemplate(reqBody *models.ChatRequest) error {
+	if reqBody.Template == "" {
+		if len(reqBody.Variables) > 0 {
+			return fmt.Errorf("variables require a template")
+		}
+		return nil
+	}
+
+	system, err := h.templates.Render(reqBody.Template, reqBody.Variables)
+	if err != nil {
+		return err
+	}
+	reqBody.Messages = append([]models.Message{{Role: openai.ChatMessageRoleSystem, Content: system}}, reqBody.Messages...)
+	return nil
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	apierrors "golang-ai-stream/errors"
+	"golang-ai-stream/models"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTemplates(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	return dir
+}
+
+func TestLoadPromptTemplates(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"support.md": "You help customers of {{.product}}.\n",
+		"terse.txt":  "Answer in one sentence.",
+		"notes.json": "{}",
+	})
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "drafts.md"), 0o755))
+
+	templates, err := LoadPromptTemplates(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{"support", "terse"}, templates.IDs())
+
+	rendered, err := templates.Render("support", map[string]string{"product": "Acme"})
+	require.NoError(t, err)
+	require.Equal(t, "You help customers of Acme.", rendered)
+
+	_, err = templates.Render("support", nil)
+	require.ErrorContains(t, err, `failed to render template "support"`)
+	_, err = templates.Render("missing", nil)
+	require.EqualError(t, err, `unknown template "missing"`)
+}
+
+func TestLoadPromptTemplates_Errors(t *testing.T) {
+	templates, err := LoadPromptTemplates("")
+	require.NoError(t, err)
+	require.Empty(t, templates.IDs())
+
+	_, err = LoadPromptTemplates(filepath.Join(t.TempDir(), "missing"))
+	require.ErrorContains(t, err, "failed to read prompt templates")
+
+	_, err = LoadPromptTemplates(writeTemplates(t, map[string]string{"broken.txt": "{{.name"}))
+	require.ErrorContains(t, err, `invalid prompt template "broken"`)
+
+	_, err = LoadPromptTemplates(writeTemplates(t, map[string]string{"a.txt": "x", "a.md": "y"}))
+	require.EqualError(t, err, `duplicate prompt template "a"`)
+}
+
+func TestHandleChat_Template(t *testing.T) {
+	templates, err := LoadPromptTemplates(writeTemplates(t, map[string]string{"support.md": "You help customers of {{.product}}."}))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		reqBody     models.ChatRequest
+		wantStatus  int
+		wantMessage string
+	}{
+		{
+			name:       "rendered",
+			reqBody:    models.ChatRequest{Prompt: "hi", Template: "support", Variables: map[string]string{"product": "Acme"}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:        "unknown template",
+			reqBody:     models.ChatRequest{Prompt: "hi", Template: "sales"},
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: `unknown template "sales"`,
+		},
+		{
+			name:        "variables without template",
+			reqBody:     models.ChatRequest{Prompt: "hi", Variables: map[string]string{"product": "Acme"}},
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "variables require a template",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &scriptedClient{}
+			handler := NewChatHandler(client, testConfig(), nil).WithPromptTemplates(templates)
+
+			w := httptest.NewRecorder()
+			handler.HandleChat(w, newTestRequest(t, "/chat", tt.reqBody))
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantMessage != "" {
+				var apiErr apierrors.APIError
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&apiErr))
+				require.Equal(t, tt.wantMessage, apiErr.Message)
+				return
+			}
+
+			messages := client.reqs[0].Messages
+			require.Len(t, messages, 2)
+			require.Equal(t, openai.ChatMessageRoleSystem, messages[0].Role)
+			require.Equal(t, "You help customers of Acme.", messages[0].Content)
+			require.Equal(t, "hi", messages[1].Content)
+		})
+	}
+}
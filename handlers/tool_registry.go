@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"golang-ai-stream/models"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ToolFunc executes a server-side tool with the JSON arguments produced by
+// the model and returns the content handed back to it
+type ToolFunc func(ctx context.Context, arguments string) (string, error)
+
+type registeredTool struct {
+	definition models.ToolFunction
+	fn         ToolFunc
+}
+
+// ToolRegistry holds the tools the server executes on the model's behalf.
+// A nil registry has no tools.
+type ToolRegistry struct {
+	mu    sync.RWMutex
+	tools map[string]registeredTool
+	order []string
+}
+
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{tools: make(map[string]registeredTool)}
+}
+
+// Register adds a tool under its function name
+func (r *ToolRegistry) Register(definition models.ToolFunction, fn ToolFunc) error {
+	if definition.Name == "" {
+		return fmt.Errorf("tool name cannot be empty")
+	}
+	if fn == nil {
+		return fmt.Errorf("tool %q has no function", definition.Name)
+	}
+	if len(definition.Parameters) > 0 && !json.Valid(definition.Parameters) {
+		return fmt.Errorf("tool %q parameters must be valid JSON", definition.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.tools[definition.Name]; exists {
+		return fmt.Errorf("tool %q is already registered", definition.Name)
+	}
+	r.tools[definition.Name] = registeredTool{definition: definition, fn: fn}
+	r.order = append(r.order, definition.Name)
+	return nil
+}
+
+// Has reports whether a tool with the given name is registered
+func (r *ToolRegistry) Has(name string) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.tools[name]
+	return ok
+}
+
+// HandlesAll reports whether every call targets a registered tool
+func (r *ToolRegistry) HandlesAll(calls []models.ToolCall) bool {
+	if len(calls) == 0 {
+		return false
+	}
+	for _, call := range calls {
+		if !r.Has(call.Function.Name) {
+			return false
+		}
+	}
+	return true
+}
+
+// Definitions returns the registered tools in registration order
+func (r *ToolRegistry) Definitions() []models.Tool {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tools := make([]models.Tool, 0, len(r.order))
+	for _, name := range r.order {
+		tools = append(tools, models.Tool{
+			Type:     string(openai.ToolTypeFunction),
+			Function: r.tools[name].definition,
+		})
+	}
+	return tools
+}
+
+// Execute runs the tool targeted by call
+func (r *ToolRegistry) Execute(ctx context.Context, call models.ToolCall) (string, error) {
+	if r == nil {
+		return "", fmt.Errorf("tool %q is not registered", call.Function.Name)
+	}
+	r.mu.RLock()
+	tool, ok := r.tools[call.Function.Name]
+	r.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("tool %q is not registered", call.Function.Name)
+	}
+	return tool.fn(ctx, call.Function.Arguments)
+}
+
+// runTools executes the calls of one assistant turn and returns the history
+// messages to send upstream next, along with the results for the client.
+// Tool failures are reported to the model as the tool output rather than
+// aborting the generation.
+func (h *ChatHandler) runTools(ctx context.Context, content string, calls []models.ToolCall) ([]openai.ChatCompletionMessage, []models.ToolResult) {
+	messages := []openai.ChatCompletionMessage{{
+		Role:      openai.ChatMessageRoleAssistant,
+		Content:   content,
+		ToolCalls: toOpenAIToolCalls(calls),
+	}}
+	results := make([]models.ToolResult, 0, len(calls))
+
+	for _, call := range calls {
+		result := models.ToolResult{ToolCallID: call.ID, Name: call.Function.Name}
+		output, err := h.tools.Execute(ctx, call)
+		if err != nil {
+			result.Content = fmt.Sprintf("error: %v", err)
+			result.IsError = true
+		} else {
+			result.Content = output
+		}
+		results = append(results, result)
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role:       openai.ChatMessageRoleTool,
+			Content:    result.Content,
+			ToolCallID: call.ID,
+		})
+	}
+	return messages, results
+}
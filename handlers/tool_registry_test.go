@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	apierrors "golang-ai-stream/errors"
+	"golang-ai-stream/models"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func weatherRegistry(t *testing.T) *ToolRegistry {
+	registry := NewToolRegistry()
+	err := registry.Register(models.ToolFunction{
+		Name:       "get_weather",
+		Parameters: json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`),
+	}, func(ctx context.Context, arguments string) (string, error) {
+		var args struct {
+			City string `json:"city"`
+		}
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf(`{"city":%q,"temp":21}`, args.City), nil
+	})
+	require.NoError(t, err)
+	return registry
+}
+
+func weatherCallStream() *scriptedStream {
+	return &scriptedStream{chunks: []openai.ChatCompletionStreamResponse{
+		toolCallChunk(0, "call_1", "get_weather", `{"city":"Paris"}`, openai.FinishReasonToolCalls),
+	}}
+}
+
+func TestToolRegistry_Register(t *testing.T) {
+	registry := weatherRegistry(t)
+	noop := func(ctx context.Context, arguments string) (string, error) { return "", nil }
+
+	require.EqualError(t, registry.Register(models.ToolFunction{}, noop), "tool name cannot be empty")
+	require.EqualError(t, registry.Register(models.ToolFunction{Name: "get_time"}, nil), `tool "get_time" has no function`)
+	require.EqualError(t, registry.Register(models.ToolFunction{Name: "get_weather"}, noop), `tool "get_weather" is already registered`)
+	require.EqualError(t, registry.Register(models.ToolFunction{Name: "bad", Parameters: json.RawMessage(`{`)}, noop),
+		`tool "bad" parameters must be valid JSON`)
+	require.NoError(t, registry.Register(models.ToolFunction{Name: "get_time"}, noop))
+
+	definitions := registry.Definitions()
+	require.Len(t, definitions, 2)
+	require.Equal(t, "get_weather", definitions[0].Function.Name)
+	require.Equal(t, "get_time", definitions[1].Function.Name)
+
+	var nilRegistry *ToolRegistry
+	require.False(t, nilRegistry.Has("get_weather"))
+	require.Empty(t, nilRegistry.Definitions())
+}
+
+func TestToolRegistry_HandlesAll(t *testing.T) {
+	registry := weatherRegistry(t)
+
+	require.False(t, registry.HandlesAll(nil))
+	require.True(t, registry.HandlesAll([]models.ToolCall{{Function: models.FunctionCall{Name: "get_weather"}}}))
+	require.False(t, registry.HandlesAll([]models.ToolCall{
+		{Function: models.FunctionCall{Name: "get_weather"}},
+		{Function: models.FunctionCall{Name: "client_tool"}},
+	}))
+}
+
+func TestHandleChat_ServerToolLoop(t *testing.T) {
+	client := &scriptedClient{streams: []*scriptedStream{
+		weatherCallStream(),
+		{chunks: []openai.ChatCompletionStreamResponse{contentChunk(0, "It is 21 degrees.", openai.FinishReasonStop)}},
+	}}
+	handler := NewChatHandler(client, testConfig(), weatherRegistry(t))
+
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Prompt: "weather in Paris?"}))
+
+	var types []string
+	var result *models.ToolResult
+	responses := collectResponses(t, w)
+	for _, resp := range responses {
+		types = append(types, resp.Type)
+		if resp.Type == "tool_result" {
+			result = resp.ToolResult
+		}
+	}
+	require.Equal(t, []string{"connected", "tool_call", "tool_result", "content", "usage", "done"}, types)
+	require.Equal(t, &models.ToolResult{ToolCallID: "call_1", Name: "get_weather", Content: `{"city":"Paris","temp":21}`}, result)
+	require.Equal(t, "stop", responses[len(responses)-1].FinishReason)
+	require.Empty(t, responses[len(responses)-1].ToolCalls)
+
+	require.Len(t, client.reqs, 2)
+	require.Len(t, client.reqs[0].Tools, 1)
+	followUp := client.reqs[1].Messages
+	require.Len(t, followUp, 3)
+	require.Equal(t, "call_1", followUp[1].ToolCalls[0].ID)
+	require.Equal(t, openai.ChatMessageRoleTool, followUp[2].Role)
+	require.Equal(t, "call_1", followUp[2].ToolCallID)
+}
+
+func TestHandleChat_ServerToolError(t *testing.T) {
+	registry := NewToolRegistry()
+	require.NoError(t, registry.Register(models.ToolFunction{Name: "get_weather"}, func(ctx context.Context, arguments string) (string, error) {
+		return "", fmt.Errorf("service unavailable")
+	}))
+	client := &scriptedClient{streams: []*scriptedStream{weatherCallStream()}}
+	handler := NewChatHandler(client, testConfig(), registry)
+
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Prompt: "weather in Paris?"}))
+
+	for _, resp := range collectResponses(t, w) {
+		if resp.Type == "tool_result" {
+			require.True(t, resp.ToolResult.IsError)
+			require.Equal(t, "error: service unavailable", resp.ToolResult.Content)
+		}
+	}
+	require.Equal(t, "error: service unavailable", client.reqs[1].Messages[2].Content)
+}
+
+func TestHandleChat_ServerToolLoopLimit(t *testing.T) {
+	client := &scriptedClient{streams: []*scriptedStream{weatherCallStream(), weatherCallStream(), weatherCallStream()}}
+	cfg := testConfig()
+	cfg.MaxToolIterations = 2
+	handler := NewChatHandler(client, cfg, weatherRegistry(t))
+
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Prompt: "weather in Paris?"}))
+
+	responses := collectResponses(t, w)
+	last := responses[len(responses)-1]
+	require.Equal(t, "error", last.Type)
+	require.Equal(t, apierrors.TypeToolLoopExceeded, last.Error.ErrorType)
+	require.Len(t, client.reqs, 3)
+}
+
+func TestHandleChat_ServerToolLoopJSON(t *testing.T) {
+	client := &scriptedClient{streams: []*scriptedStream{
+		weatherCallStream(),
+		{chunks: []openai.ChatCompletionStreamResponse{contentChunk(0, "It is 21 degrees.", openai.FinishReasonStop)}},
+	}}
+	handler := NewChatHandler(client, testConfig(), weatherRegistry(t))
+
+	stream := false
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{Prompt: "weather in Paris?", Stream: &stream}))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var response models.ChatResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	require.Equal(t, "It is 21 degrees.", response.Content)
+	require.Len(t, client.reqs, 2)
+}
+
+func TestHandleChat_ReservedToolName(t *testing.T) {
+	handler := NewChatHandler(&scriptedClient{}, testConfig(), weatherRegistry(t))
+
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{
+		Prompt: "weather in Paris?",
+		Tools:  []models.Tool{{Type: "function", Function: models.ToolFunction{Name: "get_weather"}}},
+	}))
+
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), `tool 0 name \"get_weather\" is reserved by a server tool`)
+}
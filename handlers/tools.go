@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"golang-ai-stream/models"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func validateTools(reqBody *models.ChatRequest) error {
+	for i, tool := range reqBody.Tools {
+		if tool.Type != "" && tool.Type != string(openai.ToolTypeFunction) {
+			return fmt.Errorf("tool %d has unsupported type %q", i, tool.Type)
+		}
+		if tool.Function.Name == "" {
+			return fmt.Errorf("tool %d function name cannot be empty", i)
+		}
+		if len(tool.Function.Parameters) > 0 && !json.Valid(tool.Function.Parameters) {
+			return fmt.Errorf("tool %d parameters must be valid JSON", i)
+		}
+	}
+	if len(reqBody.ToolChoice) > 0 && !json.Valid(reqBody.ToolChoice) {
+		return fmt.Errorf("tool_choice must be valid JSON")
+	}
+	return nil
+}
+
+// applyTools copies tool definitions and the tool choice onto the upstream request
+func applyTools(chatReq *openai.ChatCompletionRequest, reqBody *models.ChatRequest) {
+	appendTools(chatReq, reqBody.Tools)
+	if len(reqBody.ToolChoice) > 0 {
+		chatReq.ToolChoice = reqBody.ToolChoice
+	}
+}
+
+func appendTools(chatReq *openai.ChatCompletionRequest, tools []models.Tool) {
+	for _, tool := range tools {
+		var params any
+		if len(tool.Function.Parameters) > 0 {
+			params = tool.Function.Parameters
+		}
+		chatReq.Tools = append(chatReq.Tools, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				Parameters:  params,
+			},
+		})
+	}
+}
+
+func toOpenAIToolCalls(calls []models.ToolCall) []openai.ToolCall {
+	var result []openai.ToolCall
+	for _, call := range calls {
+		result = append(result, openai.ToolCall{
+			ID:   call.ID,
+			Type: openai.ToolTypeFunction,
+			Function: openai.FunctionCall{
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+			},
+		})
+	}
+	return result
+}
+
+func fromOpenAIToolCalls(calls []openai.ToolCall) []models.ToolCall {
+	var result []models.ToolCall
+	for _, call := range calls {
+		result = append(result, models.ToolCall{
+			ID:   call.ID,
+			Type: string(call.Type),
+			Function: models.FunctionCall{
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+			},
+		})
+	}
+	return result
+}
+
+// toolCallAssembler stitches streamed tool call fragments back together
+type toolCallAssembler struct {
+	calls map[int]*models.ToolCall
+}
+
+func newToolCallAssembler() *toolCallAssembler {
+	return &toolCallAssembler{calls: make(map[int]*models.ToolCall)}
+}
+
+// Add merges the fragments of one delta and returns them as stream deltas
+func (a *toolCallAssembler) Add(fragments []openai.ToolCall) []models.ToolCallDelta {
+	var deltas []models.ToolCallDelta
+	for position, fragment := range fragments {
+		index := position
+		if fragment.Index != nil {
+			index = *fragment.Index
+		}
+
+		call, ok := a.calls[index]
+		if !ok {
+			call = &models.ToolCall{Type: string(openai.ToolTypeFunction)}
+			a.calls[index] = call
+		}
+		if fragment.ID != "" {
+			call.ID = fragment.ID
+		}
+		if fragment.Function.Name != "" {
+			call.Function.Name = fragment.Function.Name
+		}
+		call.Function.Arguments += fragment.Function.Arguments
+
+		deltas = append(deltas, models.ToolCallDelta{
+			Index:          index,
+			ID:             fragment.ID,
+			Name:           fragment.Function.Name,
+			ArgumentsDelta: fragment.Function.Arguments,
+		})
+	}
+	return deltas
+}
+
+// Calls returns the assembled tool calls ordered by index
+func (a *toolCallAssembler) Calls() []models.ToolCall {
+	indexes := make([]int, 0, len(a.calls))
+	for index := range a.calls {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	calls := make([]models.ToolCall, 0, len(indexes))
+	for _, index := range indexes {
+		calls = append(calls, *a.calls[index])
+	}
+	return calls
+}
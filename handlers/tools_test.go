@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"golang-ai-stream/models"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func toolCallChunk(index int, id, name, arguments string, finishReason openai.FinishReason) openai.ChatCompletionStreamResponse {
+	return openai.ChatCompletionStreamResponse{
+		Choices: []openai.ChatCompletionStreamChoice{{
+			Delta: openai.ChatCompletionStreamChoiceDelta{
+				ToolCalls: []openai.ToolCall{{
+					Index:    &index,
+					ID:       id,
+					Type:     openai.ToolTypeFunction,
+					Function: openai.FunctionCall{Name: name, Arguments: arguments},
+				}},
+			},
+			FinishReason: finishReason,
+		}},
+	}
+}
+
+func TestValidateTools(t *testing.T) {
+	tests := []struct {
+		name    string
+		reqBody models.ChatRequest
+		wantErr string
+	}{
+		{
+			name: "valid tool",
+			reqBody: models.ChatRequest{Tools: []models.Tool{{
+				Type:     "function",
+				Function: models.ToolFunction{Name: "get_weather", Parameters: json.RawMessage(`{"type":"object"}`)},
+			}}, ToolChoice: json.RawMessage(`"auto"`)},
+		},
+		{
+			name:    "unsupported type",
+			reqBody: models.ChatRequest{Tools: []models.Tool{{Type: "retrieval", Function: models.ToolFunction{Name: "x"}}}},
+			wantErr: `tool 0 has unsupported type "retrieval"`,
+		},
+		{
+			name:    "missing name",
+			reqBody: models.ChatRequest{Tools: []models.Tool{{Type: "function"}}},
+			wantErr: "tool 0 function name cannot be empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTools(&tt.reqBody)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestApplyTools(t *testing.T) {
+	reqBody := &models.ChatRequest{
+		Tools: []models.Tool{{
+			Type:     "function",
+			Function: models.ToolFunction{Name: "get_weather", Parameters: json.RawMessage(`{"type":"object"}`)},
+		}},
+		ToolChoice: json.RawMessage(`"required"`),
+	}
+
+	var chatReq openai.ChatCompletionRequest
+	applyTools(&chatReq, reqBody)
+
+	payload, err := json.Marshal(chatReq)
+	require.NoError(t, err)
+	require.Contains(t, string(payload), `"tools":[{"type":"function","function":{"name":"get_weather","parameters":{"type":"object"}}}]`)
+	require.Contains(t, string(payload), `"tool_choice":"required"`)
+}
+
+func TestToolCallAssembler(t *testing.T) {
+	assembler := newToolCallAssembler()
+	first := toolCallChunk(0, "call_1", "get_weather", `{"city":`, "")
+	second := toolCallChunk(0, "", "", `"Paris"}`, "")
+
+	deltas := assembler.Add(first.Choices[0].Delta.ToolCalls)
+	require.Equal(t, []models.ToolCallDelta{{Index: 0, ID: "call_1", Name: "get_weather", ArgumentsDelta: `{"city":`}}, deltas)
+	assembler.Add(second.Choices[0].Delta.ToolCalls)
+
+	calls := assembler.Calls()
+	require.Len(t, calls, 1)
+	require.Equal(t, "call_1", calls[0].ID)
+	require.Equal(t, "get_weather", calls[0].Function.Name)
+	require.Equal(t, `{"city":"Paris"}`, calls[0].Function.Arguments)
+}
+
+func TestHandleChat_ToolCallStream(t *testing.T) {
+	client := &scriptedClient{streams: []*scriptedStream{{chunks: []openai.ChatCompletionStreamResponse{
+		toolCallChunk(0, "call_1", "get_weather", `{"city":`, ""),
+		toolCallChunk(0, "", "", `"Paris"}`, openai.FinishReasonToolCalls),
+	}}}}
+	handler := NewChatHandler(client, testConfig(), nil)
+
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{
+		Prompt: "weather in Paris?",
+		Tools:  []models.Tool{{Type: "function", Function: models.ToolFunction{Name: "get_weather"}}},
+	}))
+
+	responses := collectResponses(t, w)
+	var deltas []models.ToolCallDelta
+	for _, resp := range responses {
+		if resp.Type == "tool_call" {
+			deltas = append(deltas, *resp.ToolCall)
+		}
+	}
+	require.Len(t, deltas, 2)
+	require.Equal(t, `"Paris"}`, deltas[1].ArgumentsDelta)
+
+	done := responses[len(responses)-1]
+	require.Equal(t, "tool_calls", done.FinishReason)
+	require.Len(t, done.ToolCalls, 1)
+	require.Equal(t, `{"city":"Paris"}`, done.ToolCalls[0].Function.Arguments)
+	require.Len(t, client.reqs[0].Tools, 1)
+}
+
+func TestHandleChat_ToolResultFollowUp(t *testing.T) {
+	client := &scriptedClient{}
+	handler := NewChatHandler(client, testConfig(), nil)
+
+	w := httptest.NewRecorder()
+	handler.HandleChat(w, newTestRequest(t, "/chat", models.ChatRequest{
+		Messages: []models.Message{
+			{Role: "user", Content: "weather in Paris?"},
+			{Role: "assistant", ToolCalls: []models.ToolCall{{
+				ID: "call_1", Type: "function",
+				Function: models.FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`},
+			}}},
+			{Role: "tool", ToolCallID: "call_1", Content: `{"temp":21}`},
+		},
+	}))
+
+	require.Len(t, client.reqs, 1)
+	messages := client.reqs[0].Messages
+	require.Len(t, messages, 3)
+	require.Equal(t, "call_1", messages[1].ToolCalls[0].ID)
+	require.Equal(t, openai.ChatMessageRoleTool, messages[2].Role)
+	require.Equal(t, "call_1", messages[2].ToolCallID)
+}
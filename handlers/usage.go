@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"golang-ai-stream/models"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// Rough token estimate used when the provider does not report usage
+const (
+	charsPerToken    = 4
+	tokensPerMessage = 4
+)
+
+// usageTracker accumulates token counts and timings for the usage event
+type usageTracker struct {
+	start      time.Time
+	firstToken time.Time
+	upstream   *openai.Usage
+	completion strings.Builder
+}
+
+func newUsageTracker() *usageTracker {
+	return &usageTracker{start: time.Now()}
+}
+
+// Observe records a chunk received from the upstream stream
+func (u *usageTracker) Observe(response *openai.ChatCompletionStreamResponse) {
+	// Continuations report usage per upstream call, so accumulate it
+	if response.Usage != nil {
+		if u.upstream == nil {
+			u.upstream = &openai.Usage{}
+		}
+		u.upstream.PromptTokens += response.Usage.PromptTokens
+		u.upstream.CompletionTokens += response.Usage.CompletionTokens
+		u.upstream.TotalTokens += response.Usage.TotalTokens
+	}
+	for _, choice := range response.Choices {
+		if choice.Delta.Content == "" {
+			continue
+		}
+		if u.firstToken.IsZero() {
+			u.firstToken = time.Now()
+		}
+		u.completion.WriteString(choice.Delta.Content)
+	}
+}
+
+// Usage reports the provider's token usage, or a local estimate when the
+// provider omitted it
+func (u *usageTracker) Usage(messages []openai.ChatCompletionMessage) *models.Usage {
+	usage := &models.Usage{ElapsedMs: time.Since(u.start).Milliseconds()}
+	if !u.firstToken.IsZero() {
+		usage.TimeToFirstTokenMs = u.firstToken.Sub(u.start).Milliseconds()
+	}
+
+	if u.upstream != nil {
+		usage.PromptTokens = u.upstream.PromptTokens
+		usage.CompletionTokens = u.upstream.CompletionTokens
+		usage.TotalTokens = u.upstream.TotalTokens
+		return usage
+	}
+
+	usage.PromptTokens = estimatePromptTokens(messages)
+	usage.CompletionTokens = estimateTokens(u.completion.String())
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	usage.Estimated = true
+	return usage
+}
+
+func estimateTokens(text string) int {
+	return (len(text) + charsPerToken - 1) / charsPerToken
+}
+
+func estimatePromptTokens(messages []openai.ChatCompletionMessage) int {
+	total := 0
+	for _, msg := range messages {
+		total += estimateMessageTokens(msg)
+	}
+	return total
+}
+
+func estimateMessageTokens(msg openai.ChatCompletionMessage) int {
+	tokens := tokensPerMessage + estimateTokens(msg.Content)
+	for _, part := range msg.MultiContent {
+		tokens += estimateTokens(part.Text)
+	}
+	for _, call := range msg.ToolCalls {
+		tokens += estimateTokens(call.Function.Name) + estimateTokens(call.Function.Arguments)
+	}
+	return tokens
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageTracker_Upstream(t *testing.T) {
+	tracker := newUsageTracker()
+	chunk := contentChunk(0, "Hello", "")
+	tracker.Observe(&chunk)
+	tracker.Observe(&openai.ChatCompletionStreamResponse{
+		Usage: &openai.Usage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12},
+	})
+
+	usage := tracker.Usage(nil)
+	require.False(t, usage.Estimated)
+	require.Equal(t, 10, usage.PromptTokens)
+	require.Equal(t, 2, usage.CompletionTokens)
+	require.Equal(t, 12, usage.TotalTokens)
+	require.GreaterOrEqual(t, usage.ElapsedMs, usage.TimeToFirstTokenMs)
+}
+
+func TestUsageTracker_Estimate(t *testing.T) {
+	tracker := newUsageTracker()
+	chunk := contentChunk(0, "12345678", "")
+	tracker.Observe(&chunk)
+
+	usage := tracker.Usage([]openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleUser, Content: "123456"},
+	})
+	require.True(t, usage.Estimated)
+	require.Equal(t, 6, usage.PromptTokens) // 4 overhead + ceil(6/4)
+	require.Equal(t, 2, usage.CompletionTokens)
+	require.Equal(t, 8, usage.TotalTokens)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
@@ -12,6 +13,7 @@ import (
 	"golang-ai-stream/handlers"
 	"golang-ai-stream/logger"
 	"golang-ai-stream/middleware"
+	"golang-ai-stream/store"
 
 	"github.com/gorilla/mux"
 	"github.com/sashabaranov/go-openai"
@@ -23,21 +25,27 @@ type openAIClientWrapper struct {
 }
 
 type streamWrapper struct {
-	stream *openai.ChatCompletionStream
+	stream    *openai.ChatCompletionStream
+	reasoning map[int]string
 }
 
+// Recv decodes the raw chunk itself so that reasoning deltas, which
+// go-openai does not model, can be reported through Reasoning
 func (s *streamWrapper) Recv() (*openai.ChatCompletionStreamResponse, error) {
-	resp, err := s.stream.Recv()
+	raw, err := s.stream.RecvRaw()
 	if err != nil {
 		return nil, err
 	}
-	return &openai.ChatCompletionStreamResponse{
-		ID:      resp.ID,
-		Object:  resp.Object,
-		Created: resp.Created,
-		Model:   resp.Model,
-		Choices: resp.Choices,
-	}, nil
+	var resp openai.ChatCompletionStreamResponse
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, err
+	}
+	s.reasoning = handlers.ParseReasoning(raw)
+	return &resp, nil
+}
+
+func (s *streamWrapper) Reasoning(choice int) string {
+	return s.reasoning[choice]
 }
 
 func (s *streamWrapper) Close() {
@@ -52,6 +60,38 @@ func (w *openAIClientWrapper) CreateChatCompletionStream(ctx context.Context, re
 	return &streamWrapper{stream: stream}, nil
 }
 
+// newProviderClient builds the go-openai client for a configured backend
+func newProviderClient(provider config.ProviderConfig) handlers.OpenAIClient {
+	clientConfig := openai.DefaultConfig(provider.APIKey)
+	if provider.Type == config.ProviderAzure {
+		// Deployments are expected to be named after the model
+		clientConfig = openai.DefaultAzureConfig(provider.APIKey, provider.BaseURL)
+		clientConfig.APIVersion = provider.APIVersion
+	}
+	clientConfig.BaseURL = provider.BaseURL
+	return &openAIClientWrapper{client: openai.NewClientWithConfig(clientConfig)}
+}
+
+// newProviderRegistry registers every configured backend with its failover
+// chains and checks that the default model can be served
+func newProviderRegistry(cfg *config.Config) (*handlers.ProviderRegistry, error) {
+	registry := handlers.NewProviderRegistry()
+	for _, provider := range cfg.Providers {
+		if err := registry.Register(provider.Name, newProviderClient(provider), provider.Models); err != nil {
+			return nil, err
+		}
+	}
+	for model, targets := range cfg.Fallbacks {
+		if err := registry.SetFallbacks(model, targets); err != nil {
+			return nil, err
+		}
+	}
+	if _, _, err := registry.Route(cfg.DefaultModel); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
 func main() {
 	// Load configuration
 	cfg, err := config.LoadConfig()
@@ -60,16 +100,35 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Initialize OpenAI client
-	config := openai.DefaultConfig(cfg.APIKey)
-	config.BaseURL = cfg.BaseURL
-	client := openai.NewClientWithConfig(config)
+	// Initialize upstream providers; each model is routed to its backend
+	providers, err := newProviderRegistry(cfg)
+	if err != nil {
+		logger.LogError("", err, "Failed to initialize providers")
+		os.Exit(1)
+	}
 
-	// Wrap the OpenAI client
-	clientWrapper := &openAIClientWrapper{client: client}
+	// Tools registered here are executed by the server when the model calls them
+	toolRegistry := handlers.NewToolRegistry()
+
+	// Load system prompt templates
+	promptTemplates, err := handlers.LoadPromptTemplates(cfg.PromptTemplatesDir)
+	if err != nil {
+		logger.LogError("", err, "Failed to load prompt templates")
+		os.Exit(1)
+	}
+
+	// Initialize conversation storage
+	conversations, err := store.New(cfg.ConversationStore, cfg.ConversationsDir)
+	if err != nil {
+		logger.LogError("", err, "Failed to initialize conversation store")
+		os.Exit(1)
+	}
 
 	// Initialize handlers
-	chatHandler := handlers.NewChatHandler(clientWrapper, cfg)
+	chatHandler := handlers.NewChatHandler(providers, cfg, toolRegistry).WithPromptTemplates(promptTemplates)
+	if conversations != nil {
+		chatHandler.WithConversationStore(conversations)
+	}
 
 	// Initialize rate limiter
 	rateLimiter := middleware.NewRateLimiter(cfg.RateLimit)
@@ -83,6 +142,13 @@ func main() {
 	
 	// Routes
 	r.HandleFunc("/chat", chatHandler.HandleChat).Methods("POST", "OPTIONS")
+	r.HandleFunc("/chat/{stream_id}/stream", chatHandler.HandleStreamResume).Methods("GET", "OPTIONS")
+	r.HandleFunc("/v1/chat/completions", chatHandler.HandleChatCompletions).Methods("POST", "OPTIONS")
+	r.HandleFunc("/conversations", chatHandler.HandleListConversations).Methods("GET", "OPTIONS")
+	r.HandleFunc("/conversations/{conversation_id}", chatHandler.HandleGetConversation).Methods("GET", "OPTIONS")
+	r.HandleFunc("/conversations/{conversation_id}", chatHandler.HandleDeleteConversation).Methods("DELETE")
+	r.HandleFunc("/conversations/{conversation_id}/regenerate", chatHandler.HandleRegenerate).Methods("POST", "OPTIONS")
+	r.HandleFunc("/conversations/{conversation_id}/messages/{message_id}/branch", chatHandler.HandleBranch).Methods("POST", "OPTIONS")
 	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
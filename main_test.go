@@ -127,8 +127,15 @@ func TestRoutes(t *testing.T) {
 	r.Use(middleware.RateLimit(middleware.NewRateLimiter(cfg.RateLimit)))
 
 	// Add routes
-	chatHandler := handlers.NewChatHandler(&mockClient{}, cfg)
+	chatHandler := handlers.NewChatHandler(&mockClient{}, cfg, nil)
 	r.HandleFunc("/chat", chatHandler.HandleChat).Methods("POST", "OPTIONS")
+	r.HandleFunc("/chat/{stream_id}/stream", chatHandler.HandleStreamResume).Methods("GET", "OPTIONS")
+	r.HandleFunc("/v1/chat/completions", chatHandler.HandleChatCompletions).Methods("POST", "OPTIONS")
+	r.HandleFunc("/conversations", chatHandler.HandleListConversations).Methods("GET", "OPTIONS")
+	r.HandleFunc("/conversations/{conversation_id}", chatHandler.HandleGetConversation).Methods("GET", "OPTIONS")
+	r.HandleFunc("/conversations/{conversation_id}", chatHandler.HandleDeleteConversation).Methods("DELETE")
+	r.HandleFunc("/conversations/{conversation_id}/regenerate", chatHandler.HandleRegenerate).Methods("POST", "OPTIONS")
+	r.HandleFunc("/conversations/{conversation_id}/messages/{message_id}/branch", chatHandler.HandleBranch).Methods("POST", "OPTIONS")
 	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
@@ -153,6 +160,18 @@ func TestRoutes(t *testing.T) {
 			path:           "/chat",
 			expectedStatus: http.StatusOK,
 		},
+		{
+			name:           "chat completions options",
+			method:         "OPTIONS",
+			path:           "/v1/chat/completions",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "list conversations disabled",
+			method:         "GET",
+			path:           "/conversations",
+			expectedStatus: http.StatusNotFound,
+		},
 		{
 			name:           "not found",
 			method:         "GET",
@@ -175,4 +194,24 @@ func TestRoutes(t *testing.T) {
 			assert.Equal(t, tt.expectedStatus, rr.Code)
 		})
 	}
-} 
\ No newline at end of file
+} 
+
+func TestNewProviderRegistry(t *testing.T) {
+	cfg := &config.Config{
+		DefaultModel: "llama3",
+		Providers: []config.ProviderConfig{
+			{Name: "azure", Type: config.ProviderAzure, BaseURL: "https://example.openai.azure.com", APIVersion: "2024-06-01", Models: []string{"gpt-4o"}},
+			{Name: "local", Type: config.ProviderOpenAI, BaseURL: "http://localhost:11434/v1", Models: []string{"llama3"}},
+		},
+	}
+
+	registry, err := newProviderRegistry(cfg)
+	assert.NoError(t, err)
+	name, _, err := registry.Route("gpt-4o")
+	assert.NoError(t, err)
+	assert.Equal(t, "azure", name)
+
+	cfg.DefaultModel = "anthropic/claude-3.5-sonnet"
+	_, err = newProviderRegistry(cfg)
+	assert.EqualError(t, err, `no provider serves model "anthropic/claude-3.5-sonnet"`)
+}
@@ -147,4 +147,9 @@ func TestResponseWriter(t *testing.T) {
 		rw.Write([]byte("test"))
 		assert.Equal(t, http.StatusOK, rw.status)
 	})
+
+	t.Run("unwrap", func(t *testing.T) {
+		rw = &responseWriter{ResponseWriter: w}
+		assert.Equal(t, http.ResponseWriter(w), rw.Unwrap())
+	})
 } 
\ No newline at end of file
@@ -1,11 +1,260 @@
 package models
 
+import (
+    "bytes"
+    "encoding/json"
+)
+
+// Message is a single turn in a conversation history. Assistant turns may
+// carry tool calls, and tool turns answer one of them by ToolCallID.
+// Content is either a string or, on the wire, an array of content parts,
+// which is decoded into Parts.
+type Message struct {
+    Role       string        `json:"role"`
+    Content    string        `json:"content"`
+    Parts      []ContentPart `json:"-"`
+    ToolCalls  []ToolCall    `json:"tool_calls,omitempty"`
+    ToolCallID string        `json:"tool_call_id,omitempty"`
+}
+
+// ContentPart is one piece of multimodal message content: text or an image
+type ContentPart struct {
+    Type     string    `json:"type"`
+    Text     string    `json:"text,omitempty"`
+    ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+// ImageURL is an http(s) URL or a base64 data URI
+type ImageURL struct {
+    URL    string `json:"url"`
+    Detail string `json:"detail,omitempty"`
+}
+
+type messageJSON struct {
+    Role       string          `json:"role"`
+    Content    json.RawMessage `json:"content"`
+    ToolCalls  []ToolCall      `json:"tool_calls,omitempty"`
+    ToolCallID string          `json:"tool_call_id,omitempty"`
+}
+
+func (m *Message) UnmarshalJSON(data []byte) error {
+    var raw messageJSON
+    if err := json.Unmarshal(data, &raw); err != nil {
+        return err
+    }
+    *m = Message{Role: raw.Role, ToolCalls: raw.ToolCalls, ToolCallID: raw.ToolCallID}
+
+    content := bytes.TrimSpace(raw.Content)
+    switch {
+    case len(content) == 0 || bytes.Equal(content, []byte("null")):
+        return nil
+    case content[0] == '[':
+        return json.Unmarshal(content, &m.Parts)
+    default:
+        return json.Unmarshal(content, &m.Content)
+    }
+}
+
+func (m Message) MarshalJSON() ([]byte, error) {
+    var content any = m.Content
+    if len(m.Parts) > 0 {
+        content = m.Parts
+    }
+    encoded, err := json.Marshal(content)
+    if err != nil {
+        return nil, err
+    }
+    return json.Marshal(messageJSON{
+        Role:       m.Role,
+        Content:    encoded,
+        ToolCalls:  m.ToolCalls,
+        ToolCallID: m.ToolCallID,
+    })
+}
+
+// Tool is a function the model may call
+type Tool struct {
+    Type     string       `json:"type"`
+    Function ToolFunction `json:"function"`
+}
+
+type ToolFunction struct {
+    Name        string          `json:"name"`
+    Description string          `json:"description,omitempty"`
+    Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// ToolCall is a fully assembled call requested by the model
+type ToolCall struct {
+    ID       string       `json:"id"`
+    Type     string       `json:"type"`
+    Function FunctionCall `json:"function"`
+}
+
+type FunctionCall struct {
+    Name      string `json:"name"`
+    Arguments string `json:"arguments"`
+}
+
+// ToolResult is the output of a tool executed by the server
+type ToolResult struct {
+    ToolCallID string `json:"tool_call_id"`
+    Name       string `json:"name"`
+    Content    string `json:"content"`
+    IsError    bool   `json:"is_error,omitempty"`
+}
+
+// ToolCallDelta is one streamed fragment of a tool call
+type ToolCallDelta struct {
+    Index          int    `json:"index"`
+    ID             string `json:"id,omitempty"`
+    Name           string `json:"name,omitempty"`
+    ArgumentsDelta string `json:"arguments_delta,omitempty"`
+}
+
+// ResponseFormat requests structured output: text, json_object or json_schema
+type ResponseFormat struct {
+    Type       string      `json:"type"`
+    JSONSchema *JSONSchema `json:"json_schema,omitempty"`
+    // Repair retries once, with the validation error, when the output does not match
+    Repair bool `json:"repair,omitempty"`
+}
+
+type JSONSchema struct {
+    Name        string          `json:"name"`
+    Description string          `json:"description,omitempty"`
+    Schema      json.RawMessage `json:"schema"`
+    Strict      bool            `json:"strict,omitempty"`
+}
+
 type ChatRequest struct {
-    Prompt string `json:"prompt"`
+    Prompt   string    `json:"prompt,omitempty"`
+    Messages []Message `json:"messages,omitempty"`
+    Model    string    `json:"model,omitempty"`
+    // Stream defaults to true; false returns a single JSON response
+    Stream   *bool     `json:"stream,omitempty"`
+    // AutoContinue re-invokes the model when a reply is cut off by max_tokens
+    AutoContinue bool `json:"auto_continue,omitempty"`
+    // ConversationID continues a conversation stored on the server
+    ConversationID string `json:"conversation_id,omitempty"`
+    // ParentID branches the conversation from an earlier message instead of
+    // continuing its head; "root" starts a new first turn
+    ParentID string `json:"parent_id,omitempty"`
+    // Regenerate replaces the last assistant turn with a new sibling reply
+    Regenerate bool `json:"regenerate,omitempty"`
+    // Template selects a server-side system prompt, rendered with Variables
+    Template  string            `json:"template,omitempty"`
+    Variables map[string]string `json:"variables,omitempty"`
+    // N requests several alternative completions, streamed side by side
+    N *int `json:"n,omitempty"`
+    // IncludeReasoning streams the model's thinking as reasoning events
+    IncludeReasoning bool `json:"include_reasoning,omitempty"`
+
+    Tools []Tool `json:"tools,omitempty"`
+    // ToolChoice is "none", "auto", "required" or a named function object
+    ToolChoice json.RawMessage `json:"tool_choice,omitempty"`
+    ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+
+    // Sampling parameters, bounded by the server and defaulted per model
+    Temperature      *float32 `json:"temperature,omitempty"`
+    TopP             *float32 `json:"top_p,omitempty"`
+    MaxTokens        *int     `json:"max_tokens,omitempty"`
+    Stop             []string `json:"stop,omitempty"`
+    PresencePenalty  *float32 `json:"presence_penalty,omitempty"`
+    FrequencyPenalty *float32 `json:"frequency_penalty,omitempty"`
+    Seed             *int     `json:"seed,omitempty"`
 }
 
 type ChatResponse struct {
-    Content   string `json:"content"`
-    RequestID string `json:"request_id"`
-    Type      string `json:"type"`
-} 
\ No newline at end of file
+    Content   string       `json:"content"`
+    RequestID string       `json:"request_id"`
+    Type      string       `json:"type"`
+    // Index is the choice a content, reasoning, tool_call or done event belongs to
+    Index     *int         `json:"index,omitempty"`
+    Error     *ErrorDetail `json:"error,omitempty"`
+    Meta      *StreamMeta  `json:"meta,omitempty"`
+    Usage     *Usage       `json:"usage,omitempty"`
+    // FinishReason is set on done events: stop, length, content_filter, ...
+    FinishReason string `json:"finish_reason,omitempty"`
+    // ToolCall is set on tool_call events, ToolCalls on done events
+    ToolCall  *ToolCallDelta `json:"tool_call,omitempty"`
+    ToolCalls []ToolCall     `json:"tool_calls,omitempty"`
+    // ToolResult is set on tool_result events for server-executed tools
+    ToolResult *ToolResult `json:"tool_result,omitempty"`
+    // Reasoning is the aggregated thinking text of a JSON mode response
+    Reasoning string `json:"reasoning,omitempty"`
+    // Choices holds every alternative of a JSON mode response when n > 1
+    Choices []Choice `json:"choices,omitempty"`
+    // ConversationID, MessageID and ParentID are set on done events of
+    // stored conversations and locate the reply in the message tree
+    ConversationID string `json:"conversation_id,omitempty"`
+    MessageID      string `json:"message_id,omitempty"`
+    ParentID       string `json:"parent_id,omitempty"`
+    // Context is set on context events and JSON mode responses when history
+    // was trimmed to fit the model context window
+    Context *ContextTrim `json:"context,omitempty"`
+}
+
+// ContextTrim describes the history removed before calling the model
+type ContextTrim struct {
+    Strategy        string `json:"strategy"`
+    ContextWindow   int    `json:"context_window"`
+    DroppedMessages int    `json:"dropped_messages"`
+    // Summarized is set when the dropped turns were replaced by a summary
+    Summarized   bool `json:"summarized"`
+    TokensBefore int  `json:"tokens_before"`
+    TokensAfter  int  `json:"tokens_after"`
+}
+
+// Choice is one of several alternative completions
+type Choice struct {
+    Index        int        `json:"index"`
+    Content      string     `json:"content"`
+    FinishReason string     `json:"finish_reason,omitempty"`
+    ToolCalls    []ToolCall `json:"tool_calls,omitempty"`
+}
+
+// Usage reports token accounting and timings for a completed request
+type Usage struct {
+    PromptTokens       int   `json:"prompt_tokens"`
+    CompletionTokens   int   `json:"completion_tokens"`
+    TotalTokens        int   `json:"total_tokens"`
+    Estimated          bool  `json:"estimated"`
+    ElapsedMs          int64 `json:"elapsed_ms"`
+    TimeToFirstTokenMs int64 `json:"time_to_first_token_ms"`
+}
+
+// StreamMeta describes the stream in the initial connected event; done
+// events repeat the model and provider
+type StreamMeta struct {
+    Model string `json:"model,omitempty"`
+    // StreamID addresses the buffered stream for GET /chat/{stream_id}/stream
+    StreamID string `json:"stream_id,omitempty"`
+    // Provider is the backend that served the request, after any failover
+    Provider string        `json:"provider,omitempty"`
+    Limits   *StreamLimits `json:"limits,omitempty"`
+    // Branch is set for stored conversations
+    Branch *BranchMeta `json:"branch,omitempty"`
+}
+
+// BranchMeta tells which branch of a stored conversation a stream extends:
+// the reply will be stored as MessageID below ParentID
+type BranchMeta struct {
+    ConversationID string `json:"conversation_id"`
+    ParentID       string `json:"parent_id,omitempty"`
+    MessageID      string `json:"message_id"`
+}
+
+// StreamLimits are the server-side limits that applied to the request
+type StreamLimits struct {
+    MaxPromptLength  int `json:"max_prompt_length"`
+    MaxTotalLength   int `json:"max_total_length"`
+    MaxTokens        int `json:"max_tokens"`
+    ResumeWindowSecs int `json:"resume_window_secs"`
+}
+
+// ErrorDetail is the machine-readable part of an SSE error event
+type ErrorDetail struct {
+    ErrorType string `json:"error_type"`
+    Code      int    `json:"code,omitempty"`
+}
\ No newline at end of file
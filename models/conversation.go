@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// Conversation is a chat history stored on the server. Its messages form a
+// tree: editing or regenerating a turn adds a sibling instead of replacing
+// it, and Head is the last message of the active branch.
+type Conversation struct {
+    ID        string                `json:"id"`
+    Messages  []ConversationMessage `json:"messages"`
+    Head      string                `json:"head"`
+    CreatedAt time.Time             `json:"created_at"`
+    UpdatedAt time.Time             `json:"updated_at"`
+}
+
+// ConversationMessage is a node of the message tree. Root messages have no
+// ParentID.
+type ConversationMessage struct {
+    ID        string    `json:"id"`
+    ParentID  string    `json:"parent_id,omitempty"`
+    Message   Message   `json:"message"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+// ConversationSummary describes a stored conversation without its messages
+type ConversationSummary struct {
+    ID           string    `json:"id"`
+    MessageCount int       `json:"message_count"`
+    CreatedAt    time.Time `json:"created_at"`
+    UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (c *Conversation) Summary() ConversationSummary {
+    return ConversationSummary{
+        ID:           c.ID,
+        MessageCount: len(c.Messages),
+        CreatedAt:    c.CreatedAt,
+        UpdatedAt:    c.UpdatedAt,
+    }
+}
+
+// Find returns the message with the given ID, or nil
+func (c *Conversation) Find(id string) *ConversationMessage {
+    for i := range c.Messages {
+        if c.Messages[i].ID == id {
+            return &c.Messages[i]
+        }
+    }
+    return nil
+}
+
+// Branch returns the messages from the root down to and including leafID,
+// or nil when leafID is empty or unknown
+func (c *Conversation) Branch(leafID string) []Message {
+    var branch []Message
+    for id := leafID; id != ""; {
+        node := c.Find(id)
+        if node == nil {
+            return nil
+        }
+        branch = append([]Message{node.Message}, branch...)
+        id = node.ParentID
+    }
+    return branch
+}
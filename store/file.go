@@ -0,0 +1,136 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang-ai-stream/models"
+)
+
+// FileStore keeps each conversation as a JSON file in a directory
+type FileStore struct {
+	mu  sync.RWMutex
+	dir string
+}
+
+// NewFileStore creates the directory if needed
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create conversation directory: %v", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+func (s *FileStore) Get(ctx context.Context, id string) (*models.Conversation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.read(id)
+}
+
+func (s *FileStore) Append(ctx context.Context, id string, messages ...models.ConversationMessage) error {
+	if !ValidID(id) {
+		return fmt.Errorf("invalid conversation id %q", id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	conversation, err := s.read(id)
+	if errors.Is(err, ErrNotFound) {
+		conversation = &models.Conversation{ID: id, CreatedAt: now}
+	} else if err != nil {
+		return err
+	}
+	if err := appendMessages(conversation, messages, now); err != nil {
+		return err
+	}
+	return s.write(conversation)
+}
+
+func (s *FileStore) List(ctx context.Context) ([]models.ConversationSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conversations: %v", err)
+	}
+
+	summaries := make([]models.ConversationSummary, 0, len(entries))
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !ok || !ValidID(id) {
+			continue
+		}
+		conversation, err := s.read(id)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, conversation.Summary())
+	}
+	sortSummaries(summaries)
+	return summaries, nil
+}
+
+func (s *FileStore) Delete(ctx context.Context, id string) error {
+	if !ValidID(id) {
+		return ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := os.Remove(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *FileStore) read(id string) (*models.Conversation, error) {
+	if !ValidID(id) {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read conversation %q: %v", id, err)
+	}
+	var conversation models.Conversation
+	if err := json.Unmarshal(data, &conversation); err != nil {
+		return nil, fmt.Errorf("failed to decode conversation %q: %v", id, err)
+	}
+	return &conversation, nil
+}
+
+// write replaces the conversation file atomically so that a crash never
+// leaves a truncated history behind
+func (s *FileStore) write(conversation *models.Conversation) error {
+	data, err := json.Marshal(conversation)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir, conversation.ID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write conversation %q: %v", conversation.ID, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write conversation %q: %v", conversation.ID, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write conversation %q: %v", conversation.ID, err)
+	}
+	return os.Rename(tmp.Name(), s.path(conversation.ID))
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang-ai-stream/models"
+)
+
+// MemoryStore keeps conversations in process memory; they are lost on restart
+type MemoryStore struct {
+	mu            sync.RWMutex
+	conversations map[string]*models.Conversation
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{conversations: make(map[string]*models.Conversation)}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, id string) (*models.Conversation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	conversation, ok := s.conversations[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyConversation(conversation), nil
+}
+
+func (s *MemoryStore) Append(ctx context.Context, id string, messages ...models.ConversationMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	conversation, ok := s.conversations[id]
+	if !ok {
+		conversation = &models.Conversation{ID: id, CreatedAt: now}
+	}
+	// Validation happens on a copy so a rejected append changes nothing
+	updated := copyConversation(conversation)
+	if err := appendMessages(updated, messages, now); err != nil {
+		return err
+	}
+	s.conversations[id] = updated
+	return nil
+}
+
+func (s *MemoryStore) List(ctx context.Context) ([]models.ConversationSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	summaries := make([]models.ConversationSummary, 0, len(s.conversations))
+	for _, conversation := range s.conversations {
+		summaries = append(summaries, conversation.Summary())
+	}
+	sortSummaries(summaries)
+	return summaries, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.conversations[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.conversations, id)
+	return nil
+}
+
+// copyConversation keeps callers from mutating stored history
+func copyConversation(conversation *models.Conversation) *models.Conversation {
+	copied := *conversation
+	copied.Messages = append([]models.ConversationMessage(nil), conversation.Messages...)
+	return &copied
+}
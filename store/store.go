@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"golang-ai-stream/models"
+)
+
+// ErrNotFound is returned for conversations that do not exist
+var ErrNotFound = errors.New("conversation not found")
+
+// ErrParentNotFound is returned when appending below an unknown message
+var ErrParentNotFound = errors.New("parent message not found")
+
+// ConversationStore persists conversation histories by ID
+type ConversationStore interface {
+	// Get returns the conversation or ErrNotFound
+	Get(ctx context.Context, id string) (*models.Conversation, error)
+	// Append adds messages to the message tree of a conversation, creating
+	// it if needed, and makes the last one the head. Each message's parent
+	// must already exist or precede it in messages.
+	Append(ctx context.Context, id string, messages ...models.ConversationMessage) error
+	// List returns summaries of all conversations, most recently updated first
+	List(ctx context.Context) ([]models.ConversationSummary, error)
+	// Delete removes a conversation or returns ErrNotFound
+	Delete(ctx context.Context, id string) error
+}
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidID reports whether id can be used as a conversation ID: 1 to 64
+// letters, digits, dashes or underscores
+func ValidID(id string) bool {
+	return validID.MatchString(id)
+}
+
+// appendMessages adds messages to the tree after checking their parents
+func appendMessages(conversation *models.Conversation, messages []models.ConversationMessage, now time.Time) error {
+	known := make(map[string]bool, len(conversation.Messages)+len(messages))
+	for _, message := range conversation.Messages {
+		known[message.ID] = true
+	}
+	for _, message := range messages {
+		if message.ID == "" || known[message.ID] {
+			return fmt.Errorf("invalid message id %q", message.ID)
+		}
+		if message.ParentID != "" && !known[message.ParentID] {
+			return ErrParentNotFound
+		}
+		known[message.ID] = true
+	}
+
+	for _, message := range messages {
+		message.CreatedAt = now
+		conversation.Messages = append(conversation.Messages, message)
+	}
+	if len(messages) > 0 {
+		conversation.Head = messages[len(messages)-1].ID
+	}
+	conversation.UpdatedAt = now
+	return nil
+}
+
+func sortSummaries(summaries []models.ConversationSummary) {
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].UpdatedAt.Equal(summaries[j].UpdatedAt) {
+			return summaries[i].ID < summaries[j].ID
+		}
+		return summaries[i].UpdatedAt.After(summaries[j].UpdatedAt)
+	})
+}
+
+// New creates the store selected by kind: "memory", "file" (one JSON file
+// per conversation in dir) or "none", which disables conversations
+func New(kind, dir string) (ConversationStore, error) {
+	switch kind {
+	case "memory":
+		return NewMemoryStore(), nil
+	case "file":
+		return NewFileStore(dir)
+	case "", "none":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown conversation store %q", kind)
+}
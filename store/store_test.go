@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang-ai-stream/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func node(id, parentID, role, content string) models.ConversationMessage {
+	return models.ConversationMessage{ID: id, ParentID: parentID, Message: models.Message{Role: role, Content: content}}
+}
+
+func TestConversationStores(t *testing.T) {
+	fileStore, err := NewFileStore(filepath.Join(t.TempDir(), "conversations"))
+	require.NoError(t, err)
+
+	stores := map[string]ConversationStore{
+		"memory": NewMemoryStore(),
+		"file":   fileStore,
+	}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			_, err := s.Get(ctx, "a")
+			require.ErrorIs(t, err, ErrNotFound)
+
+			require.NoError(t, s.Append(ctx, "a", node("1", "", "user", "hi")))
+			require.NoError(t, s.Append(ctx, "b", node("1", "", "user", "hey")))
+			require.NoError(t, s.Append(ctx, "a", node("2", "1", "assistant", "hello"), node("3", "2", "user", "bye")))
+			// A second reply to the first turn forks the conversation
+			require.NoError(t, s.Append(ctx, "a", node("4", "1", "assistant", "hi there")))
+
+			conversation, err := s.Get(ctx, "a")
+			require.NoError(t, err)
+			require.Equal(t, "a", conversation.ID)
+			require.Len(t, conversation.Messages, 4)
+			require.Equal(t, "4", conversation.Head)
+			require.False(t, conversation.UpdatedAt.Before(conversation.CreatedAt))
+			require.Equal(t, []models.Message{{Role: "user", Content: "hi"}, {Role: "assistant", Content: "hi there"}}, conversation.Branch("4"))
+			require.Len(t, conversation.Branch("3"), 3)
+			require.Nil(t, conversation.Branch("missing"))
+
+			require.ErrorIs(t, s.Append(ctx, "a", node("5", "missing", "user", "x")), ErrParentNotFound)
+			require.Error(t, s.Append(ctx, "a", node("1", "", "user", "duplicate")))
+			require.ErrorIs(t, s.Append(ctx, "c", node("1", "missing", "user", "x")), ErrParentNotFound)
+			_, err = s.Get(ctx, "c")
+			require.ErrorIs(t, err, ErrNotFound)
+
+			conversation.Messages[0].Message.Content = "changed"
+			stored, err := s.Get(ctx, "a")
+			require.NoError(t, err)
+			require.Equal(t, "hi", stored.Messages[0].Message.Content)
+			require.Len(t, stored.Messages, 4)
+
+			summaries, err := s.List(ctx)
+			require.NoError(t, err)
+			require.Len(t, summaries, 2)
+			require.Equal(t, "a", summaries[0].ID)
+			require.Equal(t, 4, summaries[0].MessageCount)
+
+			require.NoError(t, s.Delete(ctx, "a"))
+			require.ErrorIs(t, s.Delete(ctx, "a"), ErrNotFound)
+			_, err = s.Get(ctx, "a")
+			require.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+func TestFileStore_InvalidID(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStore(dir)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0o644))
+
+	require.Error(t, s.Append(context.Background(), "../escape", node("1", "", "user", "x")))
+	_, err = s.Get(context.Background(), "../escape")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	summaries, err := s.List(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, summaries)
+}
+
+func TestNew(t *testing.T) {
+	s, err := New("none", "")
+	require.NoError(t, err)
+	require.Nil(t, s)
+
+	s, err = New("memory", "")
+	require.NoError(t, err)
+	require.IsType(t, &MemoryStore{}, s)
+
+	_, err = New("sqlite", "")
+	require.EqualError(t, err, `unknown conversation store "sqlite"`)
+}